@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==================== 告警通知 ====================
+// 中继服务器/规则、路由源站/域名的可达性变化与临时隧道的意外退出会生成告警，分发到配置的通知渠道：
+//   desktop  推送 alert 事件给前端弹出桌面通知
+//   webhook  以 JSON POST 到指定地址
+//   email    经 SMTP 发送邮件（支持 STARTTLS）
+//   command  执行本地命令，告警 JSON 写入标准输入，并设置 CFTUNNEL_ALERT_* 环境变量
+// 状态变化需持续超过防抖时长才会通知，恢复时发送恢复通知。
+
+const (
+	AlertDown = "down"
+	AlertUp   = "up"
+)
+
+type AlertSettings struct {
+	DebounceSeconds int         `json:"debounce_seconds"`
+	Sinks           []AlertSink `json:"sinks"`
+}
+
+type AlertSink struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // desktop / webhook / email / command
+	Disabled bool   `json:"disabled"`
+
+	URL string `json:"url,omitempty"` // webhook
+
+	SMTPHost string   `json:"smtp_host,omitempty"` // email
+	SMTPPort int      `json:"smtp_port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+
+	Command string   `json:"command,omitempty"` // command
+	Args    []string `json:"args,omitempty"`
+}
+
+type Alert struct {
+	Key     string    `json:"key"`
+	Status  string    `json:"status"` // down / up
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+func (s AlertSink) Validate() error {
+	if s.Name == "" {
+		return errors.New("通知渠道需要名称")
+	}
+	switch s.Type {
+	case "desktop":
+	case "webhook":
+		if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+			return fmt.Errorf("%s: webhook 地址无效", s.Name)
+		}
+	case "email":
+		if s.SMTPHost == "" || s.From == "" || len(s.To) == 0 {
+			return fmt.Errorf("%s: 邮件需要 SMTP 服务器、发件人和收件人", s.Name)
+		}
+	case "command":
+		if s.Command == "" {
+			return fmt.Errorf("%s: 未设置命令", s.Name)
+		}
+	default:
+		return fmt.Errorf("%s: 不支持的通知类型 %q", s.Name, s.Type)
+	}
+	return nil
+}
+
+func (a *App) GetAlertSettings() AlertSettings {
+	return loadSettings().Alerts
+}
+
+func (a *App) SaveAlertSettings(cfg AlertSettings) string {
+	if cfg.DebounceSeconds < 0 {
+		return "错误: 防抖时长不能为负数"
+	}
+	seen := map[string]bool{}
+	for _, s := range cfg.Sinks {
+		if err := s.Validate(); err != nil {
+			return "错误: " + err.Error()
+		}
+		if seen[s.Name] {
+			return "错误: 通知渠道重名: " + s.Name
+		}
+		seen[s.Name] = true
+	}
+	if _, err := updateSettings(func(s *AppSettings) { s.Alerts = cfg }); err != nil {
+		return "错误: " + err.Error()
+	}
+	return "已保存"
+}
+
+// TestAlertSink: 向指定渠道同步发送一条测试通知
+func (a *App) TestAlertSink(name string) string {
+	for _, s := range loadSettings().Alerts.Sinks {
+		if s.Name == name {
+			alert := Alert{Key: "test", Status: AlertDown, Title: "cftunnel 测试通知", Message: "这是一条测试通知", Time: time.Now()}
+			if err := a.sendAlert(s, alert); err != nil {
+				return "错误: " + err.Error()
+			}
+			return "已发送"
+		}
+	}
+	return "错误: 未找到通知渠道 " + name
+}
+
+// ---------- 状态跟踪与防抖 ----------
+
+type alertState struct {
+	down         bool      // 已通知的状态
+	pendingSince time.Time // 观察到的状态与已通知状态不同的起始时间
+}
+
+type alertTracker struct {
+	mu     sync.Mutex
+	states map[string]*alertState
+}
+
+func newAlertTracker() *alertTracker {
+	return &alertTracker{states: map[string]*alertState{}}
+}
+
+// observe: 记录一次观察结果，状态变化持续满 debounce 后返回需要发送的告警
+func (t *alertTracker) observe(key string, ok bool, debounce time.Duration, now time.Time) (status string, fire bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.states[key]
+	if st == nil {
+		st = &alertState{}
+		t.states[key] = st
+	}
+	if ok != st.down { // 观察结果与已通知状态一致
+		st.pendingSince = time.Time{}
+		return "", false
+	}
+	if st.pendingSince.IsZero() {
+		st.pendingSince = now
+	}
+	if now.Sub(st.pendingSince) < debounce {
+		return "", false
+	}
+	st.down = !ok
+	st.pendingSince = time.Time{}
+	if st.down {
+		return AlertDown, true
+	}
+	return AlertUp, true
+}
+
+// ---------- 事件来源 ----------
+
+// observeRelayCheck: 由 recordRelayCheck 调用，检查中继服务器与每条规则的远程可达性
+func (a *App) observeRelayCheck(res CheckResultInfo, err error, now time.Time) {
+	cfg := loadSettings().Alerts
+	if len(cfg.Sinks) == 0 {
+		return
+	}
+	debounce := time.Duration(cfg.DebounceSeconds) * time.Second
+
+	serverOK := err == nil && res.ServerOK
+	msg := fmt.Sprintf("中继服务器 %s 不可达", res.Server)
+	if err != nil {
+		msg = "中继检查失败: " + err.Error()
+	}
+	if serverOK {
+		msg = fmt.Sprintf("中继服务器 %s 已恢复（%d ms）", res.Server, res.ServerLatency)
+	}
+	a.raiseAlert(cfg, "relay:server", serverOK, debounce, now, "中继服务器", msg)
+
+	if err != nil {
+		return
+	}
+	for _, r := range res.Rules {
+		msg := fmt.Sprintf("规则 %s（%s 本地 %d → 远程 %d）远程不可达: %s", r.Name, r.Proto, r.LocalPort, r.RemotePort, r.RemoteErr)
+		if !r.LocalOK {
+			msg += fmt.Sprintf("；本地端口不可达: %s", r.LocalErr)
+		}
+		if r.RemoteOK {
+			msg = fmt.Sprintf("规则 %s 已恢复（%d ms）", r.Name, r.LatencyMS)
+		}
+		a.raiseAlert(cfg, "relay:rule:"+r.Name, r.RemoteOK, debounce, now, "中继规则 "+r.Name, msg)
+	}
+}
+
+// observeRouteCheck: 由 recordRouteCheck 调用，分别跟踪每条路由的源站与公网可达性
+func (a *App) observeRouteCheck(res RouteCheckResult) {
+	cfg := loadSettings().Alerts
+	if len(cfg.Sinks) == 0 {
+		return
+	}
+	debounce := time.Duration(cfg.DebounceSeconds) * time.Second
+	for _, r := range res.Routes {
+		msg := fmt.Sprintf("路由 %s 的源站 %s 不可达: %s", r.Name, r.Service, r.OriginErr)
+		if r.OriginOK {
+			msg = fmt.Sprintf("路由 %s 的源站 %s 已恢复", r.Name, r.Service)
+		}
+		a.raiseAlert(cfg, "route:origin:"+r.Name, r.OriginOK, debounce, res.CheckedAt, "路由源站 "+r.Name, msg)
+		if !r.PublicChecked {
+			continue
+		}
+		msg = fmt.Sprintf("%s 公网访问失败: %s", r.Hostname, r.PublicErr)
+		if r.PublicOK {
+			msg = fmt.Sprintf("%s 公网访问已恢复", r.Hostname)
+		}
+		a.raiseAlert(cfg, "route:public:"+r.Name, r.PublicOK, debounce, res.CheckedAt, "路由域名 "+r.Hostname, msg)
+	}
+}
+
+// alertQuickExit: cloudflared 非用户主动停止而退出时立即告警，下次启动成功后发送恢复通知
+func (a *App) alertQuickExit(waitErr error) {
+	cfg := loadSettings().Alerts
+	if len(cfg.Sinks) == 0 {
+		return
+	}
+	msg := "临时隧道 cloudflared 进程意外退出"
+	if waitErr != nil {
+		msg += ": " + waitErr.Error()
+	}
+	a.raiseAlert(cfg, "quick:tunnel", false, 0, time.Now(), "临时隧道", msg)
+}
+
+func (a *App) alertQuickStarted() {
+	cfg := loadSettings().Alerts
+	if len(cfg.Sinks) == 0 {
+		return
+	}
+	a.raiseAlert(cfg, "quick:tunnel", true, 0, time.Now(), "临时隧道", "临时隧道已重新启动")
+}
+
+func (a *App) raiseAlert(cfg AlertSettings, key string, ok bool, debounce time.Duration, now time.Time, title, msg string) {
+	status, fire := a.alerts.observe(key, ok, debounce, now)
+	if !fire {
+		return
+	}
+	prefix := "【故障】"
+	if status == AlertUp {
+		prefix = "【恢复】"
+	}
+	alert := Alert{Key: key, Status: status, Title: prefix + title, Message: msg, Time: now}
+	for _, s := range cfg.Sinks {
+		if s.Disabled {
+			continue
+		}
+		go func(s AlertSink) { _ = a.sendAlert(s, alert) }(s)
+	}
+}
+
+// ---------- 通知渠道 ----------
+
+func (a *App) sendAlert(s AlertSink, alert Alert) error {
+	switch s.Type {
+	case "desktop":
+		a.emit("alert", alert)
+		return nil
+	case "webhook":
+		return sendAlertWebhook(s.URL, alert)
+	case "email":
+		return sendAlertEmail(s, alert)
+	case "command":
+		return runAlertCommand(s, alert)
+	}
+	return fmt.Errorf("不支持的通知类型 %q", s.Type)
+}
+
+func sendAlertWebhook(url string, alert Alert) error {
+	body, _ := json.Marshal(alert)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回 %s", resp.Status)
+	}
+	return nil
+}
+
+func sendAlertEmail(s AlertSink, alert Alert) error {
+	port := s.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(s.SMTPHost, strconv.Itoa(port))
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.SMTPHost)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: =?UTF-8?B?%s?=\r\n", base64.StdEncoding.EncodeToString([]byte(alert.Title)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\n%s\r\n", alert.Message, alert.Time.Format("2006-01-02 15:04:05"))
+	return smtp.SendMail(addr, auth, s.From, s.To, msg.Bytes())
+}
+
+func runAlertCommand(s AlertSink, alert Alert) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, s.Command, s.Args...)
+	hideWindow(cmd)
+	body, _ := json.Marshal(alert)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"CFTUNNEL_ALERT_KEY="+alert.Key,
+		"CFTUNNEL_ALERT_STATUS="+alert.Status,
+		"CFTUNNEL_ALERT_TITLE="+alert.Title,
+		"CFTUNNEL_ALERT_MESSAGE="+alert.Message,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAlertTrackerDebounce(t *testing.T) {
+	tr := newAlertTracker()
+	t0 := time.Unix(0, 0)
+	debounce := time.Minute
+
+	steps := []struct {
+		ok     bool
+		after  time.Duration
+		status string
+	}{
+		{true, 0, ""},                        // 正常
+		{false, 10 * time.Second, ""},        // 开始故障，防抖中
+		{true, 20 * time.Second, ""},         // 抖动恢复，清除
+		{false, 30 * time.Second, ""},        // 再次故障
+		{false, 90 * time.Second, AlertDown}, // 持续满 1 分钟
+		{false, 150 * time.Second, ""},       // 已通知，不重复
+		{true, 160 * time.Second, ""},        // 恢复，防抖中
+		{true, 220 * time.Second, AlertUp},   // 恢复通知
+	}
+	for i, s := range steps {
+		status, fire := tr.observe("k", s.ok, debounce, t0.Add(s.after))
+		if status != s.status || fire != (s.status != "") {
+			t.Errorf("step %d: observe() = %q, %v; want %q", i, status, fire, s.status)
+		}
+	}
+}
+
+func TestAlertWebhookSink(t *testing.T) {
+	got := make(chan Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		_ = json.NewDecoder(r.Body).Decode(&a)
+		got <- a
+	}))
+	defer srv.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	if msg := a.SaveAlertSettings(AlertSettings{Sinks: []AlertSink{{Name: "hook", Type: "webhook", URL: srv.URL}}}); msg != "已保存" {
+		t.Fatal(msg)
+	}
+
+	a.recordRelayCheck(CheckResultInfo{ServerOK: true, Rules: []RuleCheckInfo{{Name: "mc", RemoteOK: false, RemoteErr: "refused"}}}, nil)
+	select {
+	case alert := <-got:
+		if alert.Key != "relay:rule:mc" || alert.Status != AlertDown {
+			t.Errorf("alert = %+v", alert)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestAlertSinkValidate(t *testing.T) {
+	bad := []AlertSink{
+		{Type: "desktop"},
+		{Name: "x", Type: "pager"},
+		{Name: "x", Type: "webhook", URL: "ftp://a"},
+		{Name: "x", Type: "email", SMTPHost: "smtp.example.com"},
+		{Name: "x", Type: "command"},
+	}
+	for _, s := range bad {
+		if s.Validate() == nil {
+			t.Errorf("Validate(%+v) = nil, want error", s)
+		}
+	}
+}
+
+func TestQuickExitAfterRestart(t *testing.T) {
+	got := make(chan Alert, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		_ = json.NewDecoder(r.Body).Decode(&a)
+		got <- a
+	}))
+	defer srv.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	if msg := a.SaveAlertSettings(AlertSettings{Sinks: []AlertSink{{Name: "hook", Type: "webhook", URL: srv.URL}}}); msg != "已保存" {
+		t.Fatal(msg)
+	}
+
+	// 停止后立即重启：旧进程的退出不影响新进程
+	oldCmd, oldStopped := &exec.Cmd{}, &atomic.Bool{}
+	a.quickCmd, a.quickStopped = oldCmd, oldStopped
+	a.markQuickStopped()
+	newCmd, newStopped := &exec.Cmd{}, &atomic.Bool{}
+	a.quickCmd, a.quickStopped = newCmd, newStopped
+
+	a.quickExited(oldCmd, oldStopped, errors.New("exit status 1"))
+	if a.quickCmd != newCmd {
+		t.Error("old process exit cleared the running tunnel")
+	}
+	select {
+	case alert := <-got:
+		t.Fatalf("unexpected alert for stopped process: %+v", alert)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	a.quickExited(newCmd, newStopped, errors.New("exit status 1"))
+	if a.quickCmd != nil {
+		t.Error("quickCmd not cleared")
+	}
+	select {
+	case alert := <-got:
+		if alert.Key != "quick:tunnel" || alert.Status != AlertDown {
+			t.Errorf("alert = %+v", alert)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+// 有规则不通时内核以非零状态退出，但 JSON 中的规则仍要告警
+func TestRelayCheckRuleFailureAlert(t *testing.T) {
+	got := make(chan Alert, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		_ = json.NewDecoder(r.Body).Decode(&a)
+		got <- a
+	}))
+	defer srv.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	if msg := a.SaveAlertSettings(AlertSettings{Sinks: []AlertSink{{Name: "hook", Type: "webhook", URL: srv.URL}}}); msg != "已保存" {
+		t.Fatal(msg)
+	}
+
+	out := `{"server":"1.2.3.4:7000","server_ok":true,"rules":[{"name":"mc","remote_ok":false,"remote_err":"refused"}],"total":1,"failed":1}`
+	res, err := parseRelayCheck(out, errors.New("exit status 1"))
+	if err != nil || !res.ServerOK || len(res.Rules) != 1 {
+		t.Fatalf("parseRelayCheck = %+v, %v", res, err)
+	}
+	if _, err := parseRelayCheck("", errors.New("exit status 1")); err == nil {
+		t.Error("empty output without error")
+	}
+
+	a.recordRelayCheck(res, err)
+	select {
+	case alert := <-got:
+		if alert.Key != "relay:rule:mc" || alert.Status != AlertDown {
+			t.Errorf("alert = %+v", alert)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ==================== 本地控制 API ====================
+// 仅监听 127.0.0.1，所有请求需携带状态目录中 api.token 的令牌：
+//   Authorization: Bearer <token>
+
+const defaultAPIPort = 17890
+
+type APIStatusInfo struct {
+	Enabled bool   `json:"enabled"`
+	Running bool   `json:"running"`
+	Addr    string `json:"addr"`
+	Token   string `json:"token"`
+	Err     string `json:"err,omitempty"`
+}
+
+type controlAPI struct {
+	addr  string
+	token string
+	stop  func()
+}
+
+func apiTokenPath() string {
+	return filepath.Join(stateDir(), "api.token")
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// loadAPIToken: 读取令牌，不存在时生成新的随机令牌
+func loadAPIToken() (string, error) {
+	if data, err := os.ReadFile(apiTokenPath()); err == nil {
+		if tok := strings.TrimSpace(string(data)); tok != "" {
+			return tok, nil
+		}
+	}
+	tok, err := randomHex(24)
+	if err != nil {
+		return "", err
+	}
+	_ = os.MkdirAll(stateDir(), 0700)
+	if err := os.WriteFile(apiTokenPath(), []byte(tok), 0600); err != nil {
+		return "", err
+	}
+	return tok, nil
+}
+
+func (a *App) GetAPIStatus() APIStatusInfo {
+	s := loadSettings()
+	info := APIStatusInfo{Enabled: s.API.Enabled}
+	a.apiMu.Lock()
+	if a.api != nil {
+		info.Running = true
+		info.Addr = a.api.addr
+		info.Token = a.api.token
+	}
+	a.apiMu.Unlock()
+	return info
+}
+
+// SetAPIEnabled: 开关控制 API 并写入设置；port 为 0 时使用默认端口
+func (a *App) SetAPIEnabled(enabled bool, port int) APIStatusInfo {
+	if port < 0 || port > 65535 {
+		return APIStatusInfo{Err: "端口无效"}
+	}
+	if _, err := updateSettings(func(s *AppSettings) {
+		s.API.Enabled = enabled
+		s.API.Port = port
+	}); err != nil {
+		return APIStatusInfo{Err: "保存设置失败: " + err.Error()}
+	}
+	a.stopAPI()
+	if enabled {
+		if err := a.startAPI(port); err != nil {
+			info := a.GetAPIStatus()
+			info.Err = "启动失败: " + err.Error()
+			return info
+		}
+	}
+	return a.GetAPIStatus()
+}
+
+func (a *App) startAPI(port int) error {
+	if port == 0 {
+		port = defaultAPIPort
+	}
+	token, err := loadAPIToken()
+	if err != nil {
+		return err
+	}
+	addr, stop, err := serveLoopback(port, a.apiHandler(token))
+	if err != nil {
+		return err
+	}
+	a.apiMu.Lock()
+	a.api = &controlAPI{addr: addr, token: token, stop: stop}
+	a.apiMu.Unlock()
+	return nil
+}
+
+func (a *App) stopAPI() {
+	a.apiMu.Lock()
+	api := a.api
+	a.api = nil
+	a.apiMu.Unlock()
+	if api != nil {
+		api.stop()
+	}
+}
+
+func (a *App) apiHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /api/quick/start", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Port string     `json:"port"`
+			Spec *QuickSpec `json:"spec"`
+		}
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var res QuickResult
+		if req.Spec != nil {
+			res = a.StartQuickSpec(*req.Spec)
+		} else {
+			res = a.StartQuick(req.Port)
+		}
+		if res.Err != "" {
+			writeJSON(w, http.StatusConflict, res)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("POST /api/quick/stop", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"message": a.QuickStop()})
+	})
+	mux.HandleFunc("GET /api/quick", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.QuickStatus())
+	})
+	mux.HandleFunc("GET /api/quick/url", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"url": a.QuickURL()})
+	})
+	mux.HandleFunc("GET /api/quick/presets", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.GetQuickPresets())
+	})
+	mux.HandleFunc("POST /api/quick/presets/{name}/start", func(w http.ResponseWriter, r *http.Request) {
+		res := a.StartQuickPreset(r.PathValue("name"))
+		if res.Err != "" {
+			writeJSON(w, http.StatusConflict, res)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("GET /api/quick/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.GetQuickStats())
+	})
+	mux.HandleFunc("GET /api/quick/requests", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.GetQuickRequests())
+	})
+	mux.HandleFunc("POST /api/quick/requests/{id}/replay", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "id 无效")
+			return
+		}
+		var o ReplayOverrides
+		if r.ContentLength != 0 {
+			if err := decodeJSONBody(w, r, &o); err != nil {
+				writeAPIError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		res := a.ReplayRequest(id, o)
+		if res.Err != "" {
+			writeJSON(w, http.StatusConflict, res)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("GET /api/share", func(w http.ResponseWriter, r *http.Request) {
+		info := a.ShareMessage(r.URL.Query().Get("target"))
+		if info.Err != "" {
+			writeJSON(w, http.StatusConflict, info)
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	})
+	mux.HandleFunc("GET /api/qr", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		size, _ := strconv.Atoi(q.Get("size"))
+		qr := a.QRCode(q.Get("target"), q.Get("format"), size)
+		if qr.Err != "" {
+			writeAPIError(w, http.StatusBadRequest, qr.Err)
+			return
+		}
+		w.Header().Set("Content-Type", qr.MIME)
+		_, _ = w.Write(qr.Data)
+	})
+	mux.HandleFunc("GET /api/routes", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, nonNil(a.GetRoutes()))
+	})
+	mux.HandleFunc("GET /api/routes/check", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.GetRouteCheck())
+	})
+	mux.HandleFunc("POST /api/routes/check", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.RouteCheck())
+	})
+	mux.HandleFunc("GET /api/routes/export", func(w http.ResponseWriter, r *http.Request) {
+		res := a.ExportRoutes(r.URL.Query().Get("format"))
+		if res.Err != "" {
+			writeJSON(w, http.StatusBadGateway, res)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("POST /api/routes/import", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Data   string `json:"data"`
+			Mode   string `json:"mode"`
+			DryRun bool   `json:"dry_run"`
+		}
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		res := a.ImportRoutes(req.Data, req.Mode, req.DryRun)
+		if res.Err != "" {
+			writeJSON(w, http.StatusConflict, res)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("GET /api/state/export", func(w http.ResponseWriter, r *http.Request) {
+		res := a.ExportState(r.URL.Query().Get("format"))
+		if res.Err != "" {
+			writeJSON(w, http.StatusBadGateway, res)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("POST /api/state/plan", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Data string `json:"data"`
+		}
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		plan := a.Plan(req.Data)
+		if plan.Err != "" {
+			writeJSON(w, http.StatusConflict, plan)
+			return
+		}
+		writeJSON(w, http.StatusOK, plan)
+	})
+	mux.HandleFunc("POST /api/state/apply", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Data string `json:"data"`
+		}
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		res := a.Apply(req.Data)
+		if res.Err != "" {
+			writeJSON(w, http.StatusConflict, res)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("GET /api/ports", func(w http.ResponseWriter, r *http.Request) {
+		res := a.ListListeningPorts()
+		if res.Err != "" {
+			writeJSON(w, http.StatusInternalServerError, res)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("GET /api/relay/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.GetRelayStatus())
+	})
+	mux.HandleFunc("GET /api/relay/rules", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, nonNil(a.GetRelayRules()))
+	})
+	mux.HandleFunc("GET /api/relay/profiles", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.GetRelayProfiles())
+	})
+	mux.HandleFunc("POST /api/relay/profiles/{name}/use", func(w http.ResponseWriter, r *http.Request) {
+		res := a.SwitchRelayProfile(r.PathValue("name"))
+		if res.Err != "" {
+			writeJSON(w, http.StatusConflict, res)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("GET /api/relay/history", func(w http.ResponseWriter, r *http.Request) {
+		rangeSeconds, _ := strconv.ParseInt(r.URL.Query().Get("range"), 10, 64)
+		writeJSON(w, http.StatusOK, a.GetRelayCheckHistory(r.URL.Query().Get("rule"), rangeSeconds))
+	})
+	mux.HandleFunc("POST /api/relay/check", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.RelayCheck())
+	})
+
+	return requireToken(token, mux)
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, "令牌无效")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return errors.New("请求体为空")
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err := dec.Decode(v); err != nil {
+		return errors.New("请求体解析失败: " + err.Error())
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"err": msg})
+}
+
+// nonNil: 空列表编码为 [] 而不是 null，方便脚本处理
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIRequiresToken(t *testing.T) {
+	a := NewApp()
+	h := a.apiHandler("secret")
+
+	tests := []struct {
+		name   string
+		auth   string
+		status int
+	}{
+		{"无令牌", "", http.StatusUnauthorized},
+		{"错误令牌", "Bearer nope", http.StatusUnauthorized},
+		{"正确令牌", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/quick/url", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestAPIQuickURL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	a.quickURL = "https://abc-def.trycloudflare.com"
+
+	req := httptest.NewRequest("GET", "/api/quick/url", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	a.apiHandler("secret").ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Body.String(), a.quickURL) {
+		t.Errorf("body = %q, want url %q", rec.Body.String(), a.quickURL)
+	}
+}
+
+func TestAPIStartQuickBadBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/quick/start", strings.NewReader("{"))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	NewApp().apiHandler("secret").ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestLoadAPITokenPersists(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	first, err := loadAPIToken()
+	if err != nil || first == "" {
+		t.Fatalf("loadAPIToken() = %q, %v", first, err)
+	}
+	second, _ := loadAPIToken()
+	if first != second {
+		t.Errorf("token changed between loads: %q != %q", first, second)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -23,14 +24,54 @@ import (
 var AppVersion = "dev"
 
 type App struct {
-	ctx      context.Context
-	quickMu  sync.Mutex
-	quickCmd *exec.Cmd
-	quickURL string
+	ctx           context.Context
+	quickMu       sync.Mutex
+	quickCmd      *exec.Cmd
+	quickURL      string
+	quickStarting bool   // startQuick 已通过检查、尚未设置 quickCmd，防止并发启动两个进程
+	prevURL       string // 上一次分配到的地址（跨重启保留），用于地址变更通知
+
+	quickOrigin http.Handler // 最近一次经前置代理的源站，用于请求重放
+	quickGate   *QuickGate   // 当前隧道的访问控制，用于生成分享信息
+	requests    *requestLog
+
+	quickLimits  QuickLimits
+	quickPreset  string    // 通过预设启动时的预设名
+	quickHooks   []URLHook // 预设自带的地址变更 webhook
+	quickPort    int       // 本机源站端口，用于端口发现标记占用
+	quickStarted time.Time
+	quickActive  atomic.Int64 // 最近一次有流量的时间（UnixNano）
+	quickStats   QuickStatsInfo
+	quickStarts  atomic.Int64
+	quickStopped *atomic.Bool // 当前进程的停止标记：用户主动停止，退出时不告警
+
+	relayMu        sync.Mutex
+	relayCheck     relayCheckState
+	relaySchedStop chan struct{}
+
+	routeMu        sync.Mutex
+	routeCheck     RouteCheckResult
+	routeSchedStop chan struct{}
+
+	alerts *alertTracker
+
+	metricsMu   sync.Mutex
+	metricsStop func()
+	metricsAddr string
+	metricsData kernelSnapshot
+
+	apiMu sync.Mutex
+	api   *controlAPI
+
+	vault *credentialVault
 }
 
 func NewApp() *App {
-	return &App{}
+	return &App{
+		requests: newRequestLog(inspectorCapacity),
+		alerts:   newAlertTracker(),
+		vault:    newCredentialVault(),
+	}
 }
 
 // startup: 程序启动时调用
@@ -40,10 +81,31 @@ func (a *App) startup(ctx context.Context) {
 	// 环境预检查：静默杀掉可能存在的残留进程
 	killProcessByName("cftunnel.exe")
 	killProcessByName("cloudflared.exe")
+
+	s := loadSettings()
+	if s.API.Enabled {
+		_ = a.startAPI(s.API.Port)
+	}
+	if s.Metrics.Enabled {
+		_ = a.startMetrics(s.Metrics.Port)
+	}
+	if s.RelayCheck.IntervalSeconds > 0 {
+		a.startRelayScheduler(s.RelayCheck)
+	}
+	if s.RouteCheck.IntervalSeconds > 0 {
+		a.startRouteScheduler(s.RouteCheck)
+	}
+	go a.startLaunchPreset(s.QuickPresets)
 }
 
 // shutdown: 程序关闭时调用
 func (a *App) shutdown(ctx context.Context) {
+	a.markQuickStopped()
+	a.stopAPI()
+	a.stopMetrics()
+	a.stopRelayScheduler()
+	a.stopRouteScheduler()
+
 	a.quickMu.Lock()
 	if a.quickCmd != nil && a.quickCmd.Process != nil {
 		_ = quickProcessKill(a.quickCmd.Process.Pid)
@@ -55,6 +117,24 @@ func (a *App) shutdown(ctx context.Context) {
 	killProcessByName("cloudflared.exe")
 }
 
+// emit: 向前端推送事件；无窗口（未启动或命令行模式）时静默忽略
+func (a *App) emit(name string, data ...interface{}) {
+	if a.ctx == nil || a.ctx.Value("events") == nil {
+		return
+	}
+	runtime.EventsEmit(a.ctx, name, data...)
+}
+
+// onSecondInstance: 第二个实例启动时，把窗口拉到前台并转交其命令行参数
+func (a *App) onSecondInstance(args []string) {
+	if a.ctx == nil || a.ctx.Value("frontend") == nil {
+		return
+	}
+	runtime.WindowUnminimise(a.ctx)
+	runtime.WindowShow(a.ctx)
+	a.emit("app:second-instance", args)
+}
+
 // --- 核心静默工具函数：解决黑窗口闪烁 ---
 
 func hideWindow(cmd *exec.Cmd) {
@@ -97,6 +177,7 @@ func quickProcessAlive(pid int) bool {
 // --- 业务逻辑 ---
 
 func (a *App) QuickStop() string {
+	a.markQuickStopped()
 	a.quickMu.Lock()
 	cmd := a.quickCmd
 	a.quickMu.Unlock()
@@ -182,8 +263,16 @@ func findCftunnel() string {
 }
 
 func runCftunnel(args ...string) (string, error) {
+	return runCftunnelInput("", args...)
+}
+
+// runCftunnelInput: stdin 非空时写入内核的标准输入
+func runCftunnelInput(stdin string, args ...string) (string, error) {
 	bin := findCftunnel()
 	cmd := exec.Command(bin, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
 	
 	// 显式设置工作目录为内核所在目录
 	// 这能保证内核里的 "." 永远指向它自己所在的文件夹
@@ -249,12 +338,63 @@ func parseRoutes(output string) []RouteInfo {
 }
 
 func (a *App) StartQuick(port string) QuickResult {
+	p, err := parseQuickPort(port)
+	if err != nil {
+		return QuickResult{Err: err.Error()}
+	}
+	return a.StartQuickSpec(QuickSpec{Scheme: "http", Host: "localhost", Port: p})
+}
+
+// StartQuickSpec: 按源站描述启动临时隧道，启动前先校验
+func (a *App) StartQuickSpec(spec QuickSpec) QuickResult {
+	return a.startQuickSpec(spec, quickRun{QuickLimits: spec.QuickLimits})
+}
+
+func (a *App) startQuickSpec(spec QuickSpec, run quickRun) QuickResult {
+	spec.normalize()
+	if err := spec.Validate(); err != nil {
+		return QuickResult{Err: err.Error()}
+	}
+	if isLoopbackHost(spec.Host) {
+		run.Port = spec.Port
+	}
+	if spec.needsProxy() {
+		return a.startQuickProxied(spec, run)
+	}
+	return a.startQuick(spec.cloudflaredArgs(), run)
+}
+
+// quickRun: 随本次 cloudflared 进程生效的运行参数
+type quickRun struct {
+	QuickLimits
+	Preset string
+	Hooks  []URLHook
+	Port   int // 本机源站端口，非本机源站为 0
+}
+
+// startQuick: 启动 cloudflared；cleanup 在启动失败或隧道退出时执行，用于关闭配套的本地服务
+func (a *App) startQuick(args []string, run quickRun, cleanup ...func()) QuickResult {
+	runCleanup := func() {
+		for _, fn := range cleanup {
+			fn()
+		}
+	}
+
 	a.quickMu.Lock()
-	if a.quickCmd != nil && a.quickCmd.Process != nil {
+	if a.quickStarting || (a.quickCmd != nil && a.quickCmd.Process != nil) {
 		a.quickMu.Unlock()
+		runCleanup()
 		return QuickResult{Err: "隧道已在运行，请先停止"}
 	}
+	a.quickStarting = true
 	a.quickMu.Unlock()
+	fail := func(msg string) QuickResult {
+		a.quickMu.Lock()
+		a.quickStarting = false
+		a.quickMu.Unlock()
+		runCleanup()
+		return QuickResult{Err: msg}
+	}
 
 	var binPath string
 	// 优先找同级目录，并转为绝对路径
@@ -271,22 +411,45 @@ func (a *App) StartQuick(port string) QuickResult {
 		binPath = filepath.Join(home, ".cftunnel", "cloudflared.exe")
 	}
 
-	cmd := exec.Command(binPath, "tunnel", "--url", "http://localhost:"+port)
+	// 指标地址仅监听回环，用于 GetQuickStats
+	metricsAddr, err := freeLoopbackAddr()
+	if err == nil {
+		args = append(append([]string(nil), args...), "--metrics", metricsAddr)
+	}
+
+	cmd := exec.Command(binPath, args...)
 	hideWindow(cmd)
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return QuickResult{Err: "创建管道失败: " + err.Error()}
+		return fail("创建管道失败: " + err.Error())
 	}
 
 	if err := cmd.Start(); err != nil {
-		return QuickResult{Err: "启动失败: " + err.Error()}
+		return fail("启动失败: " + err.Error())
 	}
 
+	stopped := &atomic.Bool{}
 	a.quickMu.Lock()
+	a.quickStarting = false
 	a.quickCmd = cmd
+	a.quickStopped = stopped
 	a.quickURL = ""
+	a.quickGate = nil
+	a.quickLimits = run.QuickLimits
+	a.quickPreset, a.quickHooks, a.quickPort = run.Preset, run.Hooks, run.Port
+	a.quickStarted = time.Now()
+	a.quickStats = QuickStatsInfo{}
 	a.quickMu.Unlock()
+	a.touchQuick()
+	a.quickStarts.Add(1)
+	a.alertQuickStarted()
+
+	done := make(chan struct{})
+	go a.watchQuick(run.QuickLimits, done)
+	if metricsAddr != "" {
+		go a.scrapeQuickMetrics(metricsAddr, done)
+	}
 
 	pidPath := quickPIDPath()
 	home, _ := os.UserHomeDir()
@@ -296,13 +459,10 @@ func (a *App) StartQuick(port string) QuickResult {
 	go a.scanQuickURL(stderr)
 
 	go func() {
-		_ = cmd.Wait()
-		a.quickMu.Lock()
-		a.quickCmd = nil
-		a.quickURL = ""
-		a.quickMu.Unlock()
-		_ = os.Remove(pidPath)
-		_ = os.Remove(quickURLPath())
+		waitErr := cmd.Wait()
+		close(done)
+		a.quickExited(cmd, stopped, waitErr)
+		runCleanup()
 	}()
 
 	for i := 0; i < 15; i++ { // Win7 启动较慢，增加等待时间
@@ -317,6 +477,39 @@ func (a *App) StartQuick(port string) QuickResult {
 	return QuickResult{URL: ""}
 }
 
+// markQuickStopped: 标记当前进程为用户主动停止
+func (a *App) markQuickStopped() {
+	a.quickMu.Lock()
+	if a.quickStopped != nil {
+		a.quickStopped.Store(true)
+	}
+	a.quickMu.Unlock()
+}
+
+// quickExited: 进程退出后清理；停止后立即重启时，旧进程退出不能清掉新进程的状态，也不告警
+func (a *App) quickExited(cmd *exec.Cmd, stopped *atomic.Bool, waitErr error) {
+	a.quickMu.Lock()
+	current := a.quickCmd == cmd
+	if current {
+		a.quickCmd = nil
+		a.quickURL = ""
+	}
+	a.quickMu.Unlock()
+	if current {
+		_ = os.Remove(quickPIDPath())
+		_ = os.Remove(quickURLPath())
+	}
+	if !stopped.Load() {
+		a.alertQuickExit(waitErr)
+	}
+}
+
+// stateDir: 应用与内核共用的状态目录 ~/.cftunnel
+func stateDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cftunnel")
+}
+
 func quickURLPath() string {
 	home, _ := os.UserHomeDir()
 	return filepath.Join(home, ".cftunnel", "quick.url")
@@ -335,9 +528,14 @@ func (a *App) scanQuickURL(r io.Reader) {
 			url := extractTunnelURL(line + "\n")
 			if url != "" {
 				a.quickMu.Lock()
+				prev, changed := a.prevURL, a.quickURL != url
 				a.quickURL = url
+				a.prevURL = url
 				a.quickMu.Unlock()
 				_ = os.WriteFile(quickURLPath(), []byte(url), 0600)
+				if changed {
+					a.onQuickURL(prev, url)
+				}
 			}
 		}
 	}
@@ -423,11 +621,11 @@ func extractTunnelURL(output string) string {
 // ==================== Relay 相关 ====================
 
 type RelayRuleInfo struct {
-	Name       string `json:"name"`
-	Proto      string `json:"proto"`
-	LocalPort  int    `json:"local_port"`
-	RemotePort int    `json:"remote_port"`
-	Domain     string `json:"domain"`
+	Name       string `json:"name" yaml:"name"`
+	Proto      string `json:"proto" yaml:"proto"`
+	LocalPort  int    `json:"local_port" yaml:"local_port"`
+	RemotePort int    `json:"remote_port" yaml:"remote_port,omitempty"`
+	Domain     string `json:"domain" yaml:"domain,omitempty"`
 }
 
 type RelayStatusInfo struct {
@@ -435,6 +633,7 @@ type RelayStatusInfo struct {
 	Running bool   `json:"running"`
 	PID     string `json:"pid"`
 	Rules   int    `json:"rules"`
+	Profile string `json:"profile,omitempty"` // 当前使用的中继配置
 }
 
 func (a *App) GetRelayStatus() RelayStatusInfo {
@@ -442,7 +641,9 @@ func (a *App) GetRelayStatus() RelayStatusInfo {
 	if err != nil {
 		return RelayStatusInfo{}
 	}
-	return parseRelayStatus(out)
+	info := parseRelayStatus(out)
+	info.Profile = loadSettings().ActiveRelayProfile
+	return info
 }
 
 func (a *App) GetRelayRules() []RelayRuleInfo {
@@ -470,20 +671,28 @@ func (a *App) RelayDown() string {
 }
 
 func (a *App) RelayAddRule(name, proto string, localPort, remotePort int, domain string) string {
-	args := []string{"relay", "add", name, "--proto", proto, "--local", fmt.Sprintf("%d", localPort)}
-	if remotePort > 0 {
-		args = append(args, "--remote", fmt.Sprintf("%d", remotePort))
-	}
-	if domain != "" {
-		args = append(args, "--domain", domain)
+	v := a.ValidateRelayRule(RelayRuleInfo{Name: name, Proto: proto, LocalPort: localPort, RemotePort: remotePort, Domain: domain})
+	if !v.Valid {
+		return "错误: " + v.message()
 	}
-	out, err := runCftunnel(args...)
+	out, err := runCftunnel(relayAddArgs(v.Rule)...)
 	if err != nil {
 		return fmt.Sprintf("错误: %s\n%s", err, out)
 	}
 	return strings.TrimSpace(out)
 }
 
+func relayAddArgs(r RelayRuleInfo) []string {
+	args := []string{"relay", "add", r.Name, "--proto", r.Proto, "--local", fmt.Sprintf("%d", r.LocalPort)}
+	if r.RemotePort > 0 {
+		args = append(args, "--remote", fmt.Sprintf("%d", r.RemotePort))
+	}
+	if r.Domain != "" {
+		args = append(args, "--domain", r.Domain)
+	}
+	return args
+}
+
 func (a *App) RelayRemoveRule(name string) string {
 	out, err := runCftunnel("relay", "remove", name)
 	if err != nil {
@@ -492,11 +701,29 @@ func (a *App) RelayRemoveRule(name string) string {
 	return strings.TrimSpace(out)
 }
 
+// RelayInit: 令牌留空时使用凭据库中该服务器的令牌；成功后凭据库已解锁则记住令牌
 func (a *App) RelayInit(server, token string) string {
-	out, err := runCftunnel("relay", "init", "--server", server, "--token", token)
+	if token == "" {
+		saved, err := a.vault.get(relayTokenKey(server))
+		if err != nil {
+			return "错误: 未填写令牌，且" + err.Error()
+		}
+		if saved == "" {
+			return "错误: 未填写令牌，凭据库中也没有该服务器的令牌"
+		}
+		token = saved
+	}
+	out, err := runCftunnelSecret(relayInitArgs(server, token)...)
 	if err != nil {
 		return fmt.Sprintf("错误: %s\n%s", err, out)
 	}
+	_ = a.vault.set(relayTokenKey(server), token)
+	// 手动初始化到其他服务器后，原先使用的中继配置不再生效
+	_, _ = updateSettings(func(s *AppSettings) {
+		if p, ok := findRelayProfile(s.RelayProfiles, s.ActiveRelayProfile); ok && p.Server != server {
+			s.ActiveRelayProfile = ""
+		}
+	})
 	return strings.TrimSpace(out)
 }
 
@@ -524,27 +751,34 @@ func (a *App) GetRelayLogs() string {
 	return strings.TrimSpace(out)
 }
 
+// RelayServerSetup: 密码与密钥都未填写时使用凭据库中保存的 SSH 密码
 func (a *App) RelayServerSetup(host string, port int, user, keyPath, password string, frpsPort int) string {
+	key := sshPasswordKey(user, host, port)
+	if password == "" && keyPath == "" {
+		password, _ = a.vault.get(key)
+	}
 	args := []string{"relay", "server", "setup", "--host", host, "-p", fmt.Sprintf("%d", port), "--user", user, "--frps-port", fmt.Sprintf("%d", frpsPort)}
 	if password != "" {
 		args = append(args, "--pass", password)
 	} else if keyPath != "" {
 		args = append(args, "--key", keyPath)
 	}
-	out, err := runCftunnel(args...)
+	out, err := runCftunnelSecret(args...)
 	if err != nil {
 		return fmt.Sprintf("错误: %s\n%s", err, out)
 	}
+	if password != "" {
+		_ = a.vault.set(key, password)
+	}
 	return strings.TrimSpace(out)
 }
 
 func (a *App) RelayCheck() CheckResultInfo {
-	out, err := runCftunnel("relay", "check", "--json")
+	result, err := runRelayCheck()
+	a.recordRelayCheck(result, err)
 	if err != nil {
 		return CheckResultInfo{}
 	}
-	var result CheckResultInfo
-	_ = json.Unmarshal([]byte(out), &result)
 	return result
 }
 
@@ -134,3 +134,24 @@ func TestParseRelayRulesFields(t *testing.T) {
 		t.Errorf("RemotePort = %d, want %d", r.RemotePort, 25565)
 	}
 }
+
+func TestStartQuickExclusive(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+
+	// 另一次启动尚未完成时拒绝，并关闭本次的配套服务
+	a.quickStarting = true
+	cleaned := false
+	if res := a.startQuick([]string{"tunnel"}, quickRun{}, func() { cleaned = true }); res.Err == "" || !cleaned {
+		t.Errorf("concurrent start: %+v, cleanup %v", res, cleaned)
+	}
+
+	// 启动失败后释放占用，可以再次启动
+	a.quickStarting = false
+	if res := a.startQuick([]string{"tunnel"}, quickRun{}); res.Err == "" {
+		t.Skip("cloudflared unexpectedly started")
+	}
+	if a.quickStarting {
+		t.Error("quickStarting left set after failed start")
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// ==================== 命令行模式 ====================
+// 同一个可执行文件带子命令运行时不打开窗口，直接调用 App 的逻辑并输出 JSON，
+// 方便计划任务和脚本调用，例如：
+//   cftunnel-app quick start --port 8080
+//   cftunnel-app relay check --json
+
+const cliUsage = `用法: cftunnel-app <命令> [参数]
+
+命令:
+  status                     内核、临时隧道与中继的整体状态
+  routes                     路由列表
+  routes check               检查路由源站与公网域名
+  routes export [--format yaml|json]
+                             导出路由
+  routes import FILE [--mode merge|replace] [--dry-run]
+                             从文件（- 表示标准输入）导入路由
+  quick start --port PORT    启动临时隧道并保持前台运行，Ctrl+C 停止
+             [--scheme http|https|tcp|ssh|rdp] [--host HOST]
+             [--no-tls-verify] [--http-host-header H] [--origin-server-name N]
+             [--ttl SECONDS] [--idle SECONDS]
+  quick start --preset NAME  按预设启动临时隧道
+  quick presets              预设列表
+  quick stop                 停止临时隧道
+  quick url                  当前临时隧道地址
+  quick status               临时隧道运行状态与剩余时间
+  ports                      本机监听端口及占用情况
+  relay status               中继状态
+  relay rules                中继规则列表
+  relay check [--json]       中继连通性检查
+  relay up | relay down      启动/停止中继
+  relay profiles             中继配置列表
+  relay use NAME             切换到指定中继配置
+  state export [--format yaml|json]
+                             导出路由与中继规则的声明式配置
+  plan FILE                  对比配置文件与当前状态，列出将执行的命令
+  apply FILE                 按配置文件执行变更，失败时回滚
+`
+
+var cliCommands = map[string]bool{
+	"status": true,
+	"routes": true,
+	"quick":  true,
+	"relay":  true,
+	"ports":  true,
+	"state":  true,
+	"plan":   true,
+	"apply":  true,
+	"help":   true,
+}
+
+func isCLICommand(name string) bool {
+	return cliCommands[name]
+}
+
+// runCLI: 执行子命令并返回进程退出码（0 成功，1 执行失败，2 用法错误）
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	a := NewApp()
+	if len(args) == 0 {
+		fmt.Fprint(stderr, cliUsage)
+		return 2
+	}
+
+	sub := ""
+	if len(args) > 1 {
+		sub = args[1]
+	}
+	switch args[0] {
+	case "help":
+		fmt.Fprint(stdout, cliUsage)
+		return 0
+	case "status":
+		return printJSON(stdout, map[string]interface{}{
+			"install": a.CheckInstall(),
+			"status":  a.GetStatus(),
+			"quick":   a.QuickStatus(),
+			"relay":   a.GetRelayStatus(),
+		})
+	case "routes":
+		switch sub {
+		case "":
+			return printJSON(stdout, nonNil(a.GetRoutes()))
+		case "check":
+			res := a.RouteCheck()
+			code := printJSON(stdout, res)
+			if code == 0 && (res.Err != "" || res.Failed > 0) {
+				code = 1
+			}
+			return code
+		case "export":
+			return cliRoutesExport(a, args[2:], stdout, stderr)
+		case "import":
+			return cliRoutesImport(a, args[2:], stdout, stderr)
+		}
+	case "ports":
+		res := a.ListListeningPorts()
+		code := printJSON(stdout, res)
+		if code == 0 && res.Err != "" {
+			code = 1
+		}
+		return code
+	case "quick":
+		switch sub {
+		case "start":
+			return cliQuickStart(a, args[2:], stdout, stderr)
+		case "stop":
+			return printJSON(stdout, map[string]string{"message": a.QuickStop()})
+		case "url":
+			return printJSON(stdout, map[string]string{"url": a.QuickURL()})
+		case "status":
+			return printJSON(stdout, a.QuickStatus())
+		case "presets":
+			return printJSON(stdout, a.GetQuickPresets())
+		}
+	case "relay":
+		switch sub {
+		case "status":
+			return printJSON(stdout, a.GetRelayStatus())
+		case "rules":
+			return printJSON(stdout, nonNil(a.GetRelayRules()))
+		case "check":
+			fs := flag.NewFlagSet("relay check", flag.ContinueOnError)
+			fs.SetOutput(stderr)
+			_ = fs.Bool("json", true, "以 JSON 输出（默认即为 JSON）")
+			if fs.Parse(args[2:]) != nil {
+				return 2
+			}
+			res := a.RelayCheck()
+			code := printJSON(stdout, res)
+			if code == 0 && res.Failed > 0 {
+				code = 1
+			}
+			return code
+		case "up":
+			return printJSON(stdout, map[string]string{"message": a.RelayUp()})
+		case "down":
+			return printJSON(stdout, map[string]string{"message": a.RelayDown()})
+		case "profiles":
+			return printJSON(stdout, a.GetRelayProfiles())
+		case "use":
+			if len(args) != 3 {
+				fmt.Fprintln(stderr, "缺少配置名")
+				return 2
+			}
+			res := a.SwitchRelayProfile(args[2])
+			code := printJSON(stdout, res)
+			if code == 0 && res.Err != "" {
+				code = 1
+			}
+			return code
+		}
+	case "state":
+		if sub == "export" {
+			return cliStateExport(a, args[2:], stdout, stderr)
+		}
+	case "plan", "apply":
+		if len(args) != 2 {
+			fmt.Fprintln(stderr, "缺少文件参数")
+			return 2
+		}
+		data, err := readCLIInput(args[1])
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if args[0] == "plan" {
+			plan := a.Plan(string(data))
+			return cliResult(stdout, plan, plan.Err)
+		}
+		res := a.Apply(string(data))
+		return cliResult(stdout, res, res.Err)
+	}
+
+	fmt.Fprintf(stderr, "未知命令: %v\n\n%s", args, cliUsage)
+	return 2
+}
+
+// cliQuickStart: 启动临时隧道后输出地址，并在前台等待，直到收到中断信号或 cloudflared 退出
+func cliQuickStart(a *App, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("quick start", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	port := fs.String("port", "", "源站端口")
+	var spec QuickSpec
+	fs.StringVar(&spec.Scheme, "scheme", "http", "源站协议 http/https/tcp/ssh/rdp")
+	fs.StringVar(&spec.Host, "host", "localhost", "源站主机")
+	fs.BoolVar(&spec.NoTLSVerify, "no-tls-verify", false, "跳过 https 源站证书校验")
+	fs.StringVar(&spec.HTTPHostHeader, "http-host-header", "", "改写发往源站的 Host 头")
+	fs.StringVar(&spec.OriginServerName, "origin-server-name", "", "https 源站的 SNI")
+	fs.IntVar(&spec.TTLSeconds, "ttl", 0, "运行多少秒后自动停止")
+	fs.IntVar(&spec.IdleSeconds, "idle", 0, "无流量多少秒后自动停止")
+	preset := fs.String("preset", "", "按预设启动，忽略其他参数")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	var res QuickResult
+	switch {
+	case *preset != "":
+		res = a.StartQuickPreset(*preset)
+	case *port == "":
+		fmt.Fprintln(stderr, "缺少 --port 或 --preset")
+		return 2
+	default:
+		p, err := parseQuickPort(*port)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		spec.Port = p
+		res = a.StartQuickSpec(spec)
+	}
+	printJSON(stdout, res)
+	if res.Err != "" {
+		return 1
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sig:
+			a.QuickStop()
+			return 0
+		case <-ticker.C:
+			if !a.QuickRunning() {
+				fmt.Fprintln(stderr, "cloudflared 已退出")
+				return 1
+			}
+		}
+	}
+}
+
+func cliRoutesExport(a *App, args []string, stdout, stderr io.Writer) int {
+	return cliExport(a.ExportRoutes, "routes export", args, stdout, stderr)
+}
+
+func cliStateExport(a *App, args []string, stdout, stderr io.Writer) int {
+	return cliExport(a.ExportState, "state export", args, stdout, stderr)
+}
+
+func cliExport(export func(format string) RouteExport, name string, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "yaml", "输出格式 yaml/json")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+	res := export(*format)
+	if res.Err != "" {
+		fmt.Fprintln(stderr, res.Err)
+		return 1
+	}
+	fmt.Fprint(stdout, res.Data)
+	return 0
+}
+
+func cliRoutesImport(a *App, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("routes import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	mode := fs.String("mode", "merge", "导入模式 merge/replace")
+	dryRun := fs.Bool("dry-run", false, "只显示差异，不执行")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "缺少文件参数")
+		return 2
+	}
+	data, err := readCLIInput(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	res := a.ImportRoutes(string(data), *mode, *dryRun)
+	return cliResult(stdout, res, res.Err)
+}
+
+// cliResult: 输出 JSON，errMsg 非空时退出码为 1
+func cliResult(stdout io.Writer, v interface{}, errMsg string) int {
+	code := printJSON(stdout, v)
+	if code == 0 && errMsg != "" {
+		code = 1
+	}
+	return code
+}
+
+// readCLIInput: 读取文件，"-" 表示标准输入
+func readCLIInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func printJSON(w io.Writer, v interface{}) int {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestRunCLIUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"无参数", nil, 2},
+		{"未知子命令", []string{"quick", "nope"}, 2},
+		{"缺少端口", []string{"quick", "start"}, 2},
+		{"帮助", []string{"help"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			if code := runCLI(tt.args, &out, &errOut); code != tt.code {
+				t.Errorf("runCLI(%v) = %d, want %d (stderr: %s)", tt.args, code, tt.code, errOut.String())
+			}
+		})
+	}
+}
+
+func TestRunCLIQuickURL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	_ = os.MkdirAll(stateDir(), 0700)
+	want := "https://foo-bar.trycloudflare.com"
+	if err := os.WriteFile(quickURLPath(), []byte(want+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	if code := runCLI([]string{"quick", "url"}, &out, &errOut); code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, errOut.String())
+	}
+	var got map[string]string
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if got["url"] != want {
+		t.Errorf("url = %q, want %q", got["url"], want)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+var procAttachConsole = syscall.NewLazyDLL("kernel32.dll").NewProc("AttachConsole")
+
+// attachConsole: GUI 子系统程序默认没有控制台，命令行模式下挂到父进程的控制台上输出
+func attachConsole() {
+	if _, err := os.Stdout.Stat(); err == nil {
+		return // 输出已被重定向到文件或管道
+	}
+	const attachParentProcess = ^uintptr(0) // ATTACH_PARENT_PROCESS (-1)
+	if r, _, _ := procAttachConsole.Call(attachParentProcess); r == 0 {
+		return
+	}
+	if f, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = f
+		os.Stderr = f
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ==================== 声明式配置 ====================
+// 一份文档描述期望的路由与中继规则，Plan 计算与当前状态（GetRoutes/GetRelayRules）的差异
+// 和需要执行的内核命令，Apply 执行这些命令，中途失败时撤销已执行的部分。
+// 文档中省略 routes 或 relay 表示不管理该部分；写成空列表表示删除全部。
+//
+//   version: 1
+//   routes:
+//     - {name: web, hostname: web.example.com, port: 3000}
+//   relay:
+//     rules:
+//       - {name: mc, proto: tcp, local_port: 25565, remote_port: 25565}
+
+type DesiredState struct {
+	Version int           `json:"version" yaml:"version"`
+	Routes  []RouteSpec   `json:"routes" yaml:"routes"` // 导出时保留空列表，否则读回时被当作不管理路由
+	Relay   *DesiredRelay `json:"relay,omitempty" yaml:"relay,omitempty"`
+}
+
+type DesiredRelay struct {
+	Rules []RelayRuleInfo `json:"rules" yaml:"rules"`
+}
+
+type RelayRuleChange struct {
+	Action string         `json:"action"` // add / change / remove
+	Name   string         `json:"name"`
+	From   *RelayRuleInfo `json:"from,omitempty"`
+	To     *RelayRuleInfo `json:"to,omitempty"`
+}
+
+type DesiredPlan struct {
+	Routes   []RouteChange     `json:"routes"`
+	Relay    []RelayRuleChange `json:"relay"`
+	Commands []string          `json:"commands"` // 将要执行的内核命令
+	Errors   []string          `json:"errors,omitempty"`
+	Err      string            `json:"err,omitempty"`
+}
+
+type DesiredApplyResult struct {
+	Plan DesiredPlan `json:"plan"`
+	StepsOutcome
+	Err string `json:"err,omitempty"`
+}
+
+// Plan: 只计算差异，不执行
+func (a *App) Plan(data string) DesiredPlan {
+	plan, _ := planDesired(data, currentStateReader{})
+	return plan
+}
+
+// Apply: 重新计算差异并执行
+func (a *App) Apply(data string) DesiredApplyResult {
+	return applyDesired(data, currentStateReader{}, runCftunnel)
+}
+
+// ExportState: 把当前路由与中继规则导出为声明式文档，作为纳入版本管理的起点
+func (a *App) ExportState(format string) RouteExport {
+	var r currentStateReader
+	routes, err := r.routes()
+	if err != nil {
+		return RouteExport{Err: err.Error()}
+	}
+	rules, err := r.relayRules()
+	if err != nil {
+		return RouteExport{Err: err.Error()}
+	}
+	doc := DesiredState{Version: routeDocVersion, Routes: []RouteSpec{}, Relay: &DesiredRelay{Rules: nonNil(rules)}}
+	for _, rt := range routes {
+		doc.Routes = append(doc.Routes, routeSpecFromInfo(rt))
+	}
+	data, format, err := encodeDocument(doc, format)
+	if err != nil {
+		return RouteExport{Err: err.Error()}
+	}
+	return RouteExport{Format: format, Data: data}
+}
+
+// stateReader: 读取当前状态，测试中可替换
+type stateReader interface {
+	routes() ([]RouteInfo, error)
+	relayRules() ([]RelayRuleInfo, error)
+}
+
+type currentStateReader struct{}
+
+func (currentStateReader) routes() ([]RouteInfo, error) { return listRoutes() }
+
+func (currentStateReader) relayRules() ([]RelayRuleInfo, error) {
+	out, err := runCftunnel("relay", "list")
+	if err != nil {
+		return nil, fmt.Errorf("读取中继规则失败: %v: %s", err, strings.TrimSpace(out))
+	}
+	return parseRelayRules(out), nil
+}
+
+func applyDesired(data string, state stateReader, run cftunnelRunner) DesiredApplyResult {
+	plan, steps := planDesired(data, state)
+	res := DesiredApplyResult{Plan: plan, Err: plan.Err}
+	if plan.Err != "" || len(steps) == 0 {
+		return res
+	}
+	var err error
+	if res.StepsOutcome, err = applySteps(run, steps); err != nil {
+		res.Err = err.Error()
+		if res.RolledBack && len(res.RollbackErrors) == 0 {
+			res.Err += "（已回滚）"
+		}
+	}
+	return res
+}
+
+func planDesired(data string, state stateReader) (DesiredPlan, []cftunnelStep) {
+	plan := DesiredPlan{Routes: []RouteChange{}, Relay: []RelayRuleChange{}, Commands: []string{}}
+	var doc DesiredState
+	if err := decodeDocument(data, &doc, nil); err != nil {
+		plan.Err = err.Error()
+		return plan, nil
+	}
+	if doc.Version > routeDocVersion {
+		plan.Err = fmt.Sprintf("不支持的文档版本 %d", doc.Version)
+		return plan, nil
+	}
+	if doc.Routes != nil {
+		plan.Errors = append(plan.Errors, validateRouteSpecs(doc.Routes)...)
+	}
+	if doc.Relay != nil {
+		plan.Errors = append(plan.Errors, validateRelayRules(doc.Relay.Rules)...)
+	}
+	if len(plan.Errors) > 0 {
+		plan.Err = "文档校验失败"
+		return plan, nil
+	}
+
+	var steps []cftunnelStep
+	if doc.Routes != nil {
+		current, err := state.routes()
+		if err != nil {
+			plan.Err = err.Error()
+			return plan, nil
+		}
+		plan.Routes = diffRoutes(current, doc.Routes, true)
+		steps = append(steps, routeSteps(plan.Routes)...)
+	}
+	if doc.Relay != nil {
+		current, err := state.relayRules()
+		if err != nil {
+			plan.Err = err.Error()
+			return plan, nil
+		}
+		plan.Relay = diffRelayRules(current, doc.Relay.Rules)
+		steps = append(steps, relaySteps(plan.Relay)...)
+	}
+	for _, s := range steps {
+		plan.Commands = append(plan.Commands, "cftunnel "+strings.Join(s.Args, " "))
+	}
+	return plan, steps
+}
+
+// validateRelayRules: 逐条校验，并与文档中排在前面的规则比较名称与端口冲突
+func validateRelayRules(rules []RelayRuleInfo) []string {
+	var errs []string
+	for i := range rules {
+		for _, e := range validateRelayRule(&rules[i], rules[:i]) {
+			errs = append(errs, fmt.Sprintf("第 %d 条中继规则 %s: %s", i+1, rules[i].Name, e.Message))
+		}
+	}
+	return errs
+}
+
+// diffRelayRules: 按名称比较；期望的远程端口为 0 表示由服务端分配，不参与比较
+func diffRelayRules(current, desired []RelayRuleInfo) []RelayRuleChange {
+	have := map[string]RelayRuleInfo{}
+	for _, r := range current {
+		have[r.Name] = r
+	}
+	changes := []RelayRuleChange{}
+	want := map[string]bool{}
+	for _, d := range desired {
+		d := d
+		want[d.Name] = true
+		cur, ok := have[d.Name]
+		switch {
+		case !ok:
+			changes = append(changes, RelayRuleChange{Action: "add", Name: d.Name, To: &d})
+		case !relayRuleMatches(cur, d):
+			cur := cur
+			changes = append(changes, RelayRuleChange{Action: "change", Name: d.Name, From: &cur, To: &d})
+		}
+	}
+	for _, r := range current {
+		if !want[r.Name] {
+			r := r
+			changes = append(changes, RelayRuleChange{Action: "remove", Name: r.Name, From: &r})
+		}
+	}
+	order := map[string]int{"remove": 0, "change": 1, "add": 2}
+	sort.SliceStable(changes, func(i, j int) bool { return order[changes[i].Action] < order[changes[j].Action] })
+	return changes
+}
+
+func relayRuleMatches(cur, want RelayRuleInfo) bool {
+	return strings.EqualFold(cur.Proto, want.Proto) &&
+		cur.LocalPort == want.LocalPort &&
+		(want.RemotePort == 0 || cur.RemotePort == want.RemotePort) &&
+		strings.EqualFold(cur.Domain, want.Domain)
+}
+
+func relaySteps(changes []RelayRuleChange) []cftunnelStep {
+	var steps []cftunnelStep
+	for _, c := range changes {
+		if c.From != nil {
+			steps = append(steps, cftunnelStep{
+				Desc: "删除中继规则 " + c.Name,
+				Args: []string{"relay", "remove", c.Name},
+				Undo: relayAddArgs(*c.From),
+			})
+		}
+		if c.To != nil {
+			steps = append(steps, cftunnelStep{
+				Desc: "添加中继规则 " + c.Name,
+				Args: relayAddArgs(*c.To),
+				Undo: []string{"relay", "remove", c.Name},
+			})
+		}
+	}
+	return steps
+}
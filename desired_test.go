@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeState struct {
+	routeList []RouteInfo
+	rules     []RelayRuleInfo
+}
+
+func (f fakeState) routes() ([]RouteInfo, error)         { return f.routeList, nil }
+func (f fakeState) relayRules() ([]RelayRuleInfo, error) { return f.rules, nil }
+
+var testState = fakeState{
+	routeList: []RouteInfo{
+		{Name: "web", Hostname: "web.example.com", Service: "http://localhost:3000"},
+		{Name: "old", Hostname: "old.example.com", Service: "http://localhost:9000"},
+	},
+	rules: []RelayRuleInfo{
+		{Name: "mc", Proto: "tcp", LocalPort: 25565, RemotePort: 25565},
+		{Name: "dns", Proto: "udp", LocalPort: 53, RemotePort: 5353},
+	},
+}
+
+func TestPlanDesired(t *testing.T) {
+	doc := `
+version: 1
+routes:
+  - {name: web, hostname: web.example.com, port: 3000}
+relay:
+  rules:
+    - {name: mc, proto: TCP, local_port: 25565}
+    - {name: dns, proto: udp, local_port: 53, remote_port: 5300}
+    - {name: ssh, proto: tcp, local_port: 22, remote_port: 2222}
+`
+	plan, steps := planDesired(doc, testState)
+	if plan.Err != "" {
+		t.Fatal(plan.Err, plan.Errors)
+	}
+	want := []string{
+		"cftunnel remove old",
+		"cftunnel relay remove dns",
+		"cftunnel relay add dns --proto udp --local 53 --remote 5300",
+		"cftunnel relay add ssh --proto tcp --local 22 --remote 2222",
+	}
+	if !reflect.DeepEqual(plan.Commands, want) || len(steps) != len(want) {
+		t.Errorf("commands = %q", plan.Commands)
+	}
+
+	// 省略 relay 不管理中继；空的 routes 列表表示删除全部路由
+	plan, _ = planDesired("routes: []", testState)
+	if len(plan.Relay) != 0 || len(plan.Routes) != 2 {
+		t.Errorf("plan = %+v", plan)
+	}
+	plan, _ = planDesired("version: 1", testState)
+	if len(plan.Commands) != 0 {
+		t.Errorf("empty doc commands = %q", plan.Commands)
+	}
+}
+
+// 导出的空路由列表读回后仍表示删除全部路由
+func TestDesiredEmptyRoutesRoundTrip(t *testing.T) {
+	doc := DesiredState{Version: routeDocVersion, Routes: []RouteSpec{}}
+	for _, format := range []string{"yaml", "json"} {
+		data, _, err := encodeDocument(doc, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, _ := planDesired(data, testState)
+		if len(plan.Routes) != 2 {
+			t.Errorf("%s: %q plan = %+v", format, data, plan)
+		}
+	}
+}
+
+func TestPlanDesiredValidation(t *testing.T) {
+	plan, _ := planDesired(`
+relay:
+  rules:
+    - {name: a, proto: sctp, local_port: 1}
+    - {name: b, proto: tcp, local_port: 0}
+    - {name: c, proto: tcp, local_port: 1, remote_port: 70000}
+routes:
+  - {name: r, hostname: bad, port: 1}
+`, testState)
+	if plan.Err == "" || len(plan.Errors) != 4 {
+		t.Errorf("errors = %q", plan.Errors)
+	}
+	if plan, _ := planDesired("- a\n- b", testState); plan.Err == "" {
+		t.Error("list document accepted")
+	}
+}
+
+func TestApplyDesiredRollback(t *testing.T) {
+	doc := `
+routes:
+  - {name: web, hostname: web.example.com, port: 3001}
+  - {name: old, hostname: old.example.com, port: 9000}
+`
+	var calls []string
+	run := func(args ...string) (string, error) {
+		cmd := strings.Join(args, " ")
+		calls = append(calls, cmd)
+		if strings.HasPrefix(cmd, "add web 3001") {
+			return "端口被占用", errors.New("exit status 1")
+		}
+		return "", nil
+	}
+	res := applyDesired(doc, testState, run)
+	want := []string{
+		"remove web",
+		"add web 3001 --domain web.example.com", // 失败
+		"add web 3000 --domain web.example.com", // 撤销删除
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q", calls)
+	}
+	if !res.RolledBack || res.Applied != 1 || len(res.RollbackErrors) != 0 || !strings.Contains(res.Err, "已回滚") {
+		t.Errorf("res = %+v", res)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==================== Prometheus 指标导出 ====================
+// 可选的回环 /metrics 端点，导出临时隧道、路由、中继状态与最近一次路由/中继检查结果。
+// 检查结果取自最近一次 RouteCheck/RelayCheck，抓取时不会主动发起检查。
+// 路由列表与中继状态需要调用内核，缓存 metricsCacheTTL，频繁抓取不会反复启动子进程。
+
+const (
+	defaultMetricsPort = 17891
+	metricsCacheTTL    = 10 * time.Second
+)
+
+type MetricsStatusInfo struct {
+	Enabled bool   `json:"enabled"`
+	Running bool   `json:"running"`
+	Addr    string `json:"addr"`
+	Err     string `json:"err,omitempty"`
+}
+
+func (a *App) GetMetricsStatus() MetricsStatusInfo {
+	info := MetricsStatusInfo{Enabled: loadSettings().Metrics.Enabled}
+	a.metricsMu.Lock()
+	if a.metricsStop != nil {
+		info.Running = true
+		info.Addr = a.metricsAddr
+	}
+	a.metricsMu.Unlock()
+	return info
+}
+
+// SetMetricsEnabled: 开关指标端点并写入设置；port 为 0 时使用默认端口
+func (a *App) SetMetricsEnabled(enabled bool, port int) MetricsStatusInfo {
+	if port < 0 || port > 65535 {
+		return MetricsStatusInfo{Err: "端口无效"}
+	}
+	if _, err := updateSettings(func(s *AppSettings) {
+		s.Metrics.Enabled = enabled
+		s.Metrics.Port = port
+	}); err != nil {
+		return MetricsStatusInfo{Err: "保存设置失败: " + err.Error()}
+	}
+	a.stopMetrics()
+	if enabled {
+		if err := a.startMetrics(port); err != nil {
+			info := a.GetMetricsStatus()
+			info.Err = "启动失败: " + err.Error()
+			return info
+		}
+	}
+	return a.GetMetricsStatus()
+}
+
+func (a *App) startMetrics(port int) error {
+	if port == 0 {
+		port = defaultMetricsPort
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		routes, relay := a.metricsData.get(time.Now(), func() ([]RouteInfo, RelayStatusInfo) {
+			return a.GetRoutes(), a.GetRelayStatus()
+		})
+		a.writeMetrics(w, routes, relay)
+	})
+	addr, stop, err := serveLoopback(port, mux)
+	if err != nil {
+		return err
+	}
+	a.metricsMu.Lock()
+	a.metricsStop, a.metricsAddr = stop, addr
+	a.metricsMu.Unlock()
+	return nil
+}
+
+func (a *App) stopMetrics() {
+	a.metricsMu.Lock()
+	stop := a.metricsStop
+	a.metricsStop, a.metricsAddr = nil, ""
+	a.metricsMu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// kernelSnapshot: 读取期间持锁，并发的抓取等待同一次内核调用而不是各自再调用
+type kernelSnapshot struct {
+	mu     sync.Mutex
+	at     time.Time
+	routes []RouteInfo
+	relay  RelayStatusInfo
+}
+
+func (c *kernelSnapshot) get(now time.Time, load func() ([]RouteInfo, RelayStatusInfo)) ([]RouteInfo, RelayStatusInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.at.IsZero() || now.Sub(c.at) >= metricsCacheTTL {
+		c.routes, c.relay = load()
+		c.at = now
+	}
+	return c.routes, c.relay
+}
+
+func (a *App) writeMetrics(w io.Writer, routes []RouteInfo, relay RelayStatusInfo) {
+	p := &promWriter{w: w, seen: map[string]bool{}}
+
+	starts := a.quickStarts.Load()
+	p.gauge("cftunnel_app_quick_tunnel_up", "临时隧道是否在运行", boolFloat(a.QuickRunning()))
+	p.counter("cftunnel_app_quick_tunnel_starts_total", "本次运行期间临时隧道启动次数", float64(starts))
+	p.counter("cftunnel_app_quick_tunnel_restarts_total", "本次运行期间临时隧道重启次数（首次启动之后）", float64(max(starts-1, 0)))
+	if stats := a.GetQuickStats(); stats.Available {
+		p.counter("cftunnel_app_quick_requests_total", "经临时隧道的请求数（cloudflared 指标）", float64(stats.TotalRequests))
+		p.gauge("cftunnel_app_quick_ha_connections", "临时隧道边缘连接数", float64(stats.HAConnections))
+	}
+
+	p.gauge("cftunnel_app_routes", "已配置的路由数", float64(len(routes)))
+	a.routeMu.Lock()
+	routeCheck := a.routeCheck
+	a.routeMu.Unlock()
+	for _, r := range routeCheck.Routes {
+		labels := []string{"route", r.Name, "hostname", r.Hostname}
+		p.gauge("cftunnel_app_route_origin_up", "路由源站是否可达（最近一次路由检查）", boolFloat(r.OriginOK), labels...)
+		p.gauge("cftunnel_app_route_origin_latency_ms", "路由源站检查耗时（毫秒）", float64(r.OriginLatencyMS), labels...)
+		if r.PublicChecked {
+			p.gauge("cftunnel_app_route_public_up", "路由公网域名是否可达", boolFloat(r.PublicOK), labels...)
+		}
+	}
+	p.gauge("cftunnel_app_relay_running", "中继客户端是否在运行", boolFloat(relay.Running))
+	p.gauge("cftunnel_app_relay_rules", "中继规则数", float64(relay.Rules))
+
+	a.relayMu.Lock()
+	st := a.relayCheck
+	a.relayMu.Unlock()
+	p.counter("cftunnel_app_relay_checks_total", "中继检查次数", float64(st.total))
+	p.counter("cftunnel_app_relay_check_failures_total", "失败的中继检查次数", float64(st.failures))
+	if st.lastAt.IsZero() {
+		return
+	}
+	p.gauge("cftunnel_app_relay_last_check_timestamp_seconds", "最近一次中继检查时间", float64(st.lastAt.Unix()))
+	p.gauge("cftunnel_app_relay_server_up", "中继服务器是否可达", boolFloat(st.last.ServerOK), "server", st.last.Server)
+	p.gauge("cftunnel_app_relay_server_latency_ms", "中继服务器延迟（毫秒）", float64(st.last.ServerLatency), "server", st.last.Server)
+
+	rules := append([]RuleCheckInfo(nil), st.last.Rules...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	for _, r := range rules {
+		labels := []string{"rule", r.Name, "proto", r.Proto, "remote_port", strconv.Itoa(r.RemotePort)}
+		p.gauge("cftunnel_app_relay_rule_local_ok", "中继规则本地端口是否可达", boolFloat(r.LocalOK), labels...)
+		p.gauge("cftunnel_app_relay_rule_remote_ok", "中继规则远程端口是否可达", boolFloat(r.RemoteOK), labels...)
+		p.gauge("cftunnel_app_relay_rule_latency_ms", "中继规则远程延迟（毫秒）", float64(r.LatencyMS), labels...)
+	}
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// promWriter: 按 Prometheus 文本格式输出，同名指标只写一次 HELP/TYPE
+type promWriter struct {
+	w    io.Writer
+	seen map[string]bool
+}
+
+func (p *promWriter) gauge(name, help string, v float64, labels ...string) {
+	p.write(name, "gauge", help, v, labels)
+}
+
+func (p *promWriter) counter(name, help string, v float64, labels ...string) {
+	p.write(name, "counter", help, v, labels)
+}
+
+func (p *promWriter) write(name, typ, help string, v float64, labels []string) {
+	if !p.seen[name] {
+		p.seen[name] = true
+		fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i])
+			b.WriteString(`="`)
+			b.WriteString(promEscaper.Replace(labels[i+1]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(p.w, "%s %s\n", b.String(), strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	a.recordRelayCheck(CheckResultInfo{
+		Server:        "1.2.3.4:7000",
+		ServerOK:      true,
+		ServerLatency: 35,
+		Rules: []RuleCheckInfo{
+			{Name: "ssh", Proto: "tcp", RemotePort: 6022, LocalOK: true, RemoteOK: false, LatencyMS: 40},
+		},
+		Failed: 1,
+	}, nil)
+	a.recordRelayCheck(CheckResultInfo{}, errors.New("exit status 1"))
+
+	var b strings.Builder
+	a.writeMetrics(&b, []RouteInfo{{Name: "web"}}, RelayStatusInfo{Running: true, Rules: 1})
+	out := b.String()
+
+	// 第二次检查失败且无结果，最近结果为空
+	for _, want := range []string{
+		"cftunnel_app_quick_tunnel_up 0",
+		"cftunnel_app_routes 1",
+		"cftunnel_app_relay_running 1",
+		"cftunnel_app_relay_checks_total 2",
+		"cftunnel_app_relay_check_failures_total 2",
+		`cftunnel_app_relay_server_up{server=""} 0`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("metrics missing %q\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "# TYPE cftunnel_app_relay_checks_total counter") != 1 {
+		t.Errorf("TYPE line should be written exactly once")
+	}
+}
+
+func TestWriteMetricsRuleLabels(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	a.recordRelayCheck(CheckResultInfo{ServerOK: true, Rules: []RuleCheckInfo{
+		{Name: `we"b`, Proto: "http", RemotePort: 80, LocalOK: true, RemoteOK: true},
+	}}, nil)
+
+	var b strings.Builder
+	a.writeMetrics(&b, nil, RelayStatusInfo{})
+	want := `cftunnel_app_relay_rule_remote_ok{rule="we\"b",proto="http",remote_port="80"} 1`
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("metrics missing %q\n%s", want, b.String())
+	}
+}
+
+func TestKernelSnapshotCache(t *testing.T) {
+	var c kernelSnapshot
+	loads := 0
+	load := func() ([]RouteInfo, RelayStatusInfo) {
+		loads++
+		return []RouteInfo{{Name: "web"}}, RelayStatusInfo{Rules: loads}
+	}
+	t0 := time.Now()
+	c.get(t0, load)
+	if _, relay := c.get(t0.Add(metricsCacheTTL/2), load); loads != 1 || relay.Rules != 1 {
+		t.Errorf("cached get reloaded: loads = %d", loads)
+	}
+	if _, relay := c.get(t0.Add(metricsCacheTTL), load); loads != 2 || relay.Rules != 2 {
+		t.Errorf("expired get not reloaded: loads = %d", loads)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ==================== 目录分享 ====================
+// 内置静态文件服务绑定在回环随机端口，由临时隧道对外暴露，二者一起启停。
+// 支持 index.html、Range 请求（均由 http.FileServer 提供）、目录列表开关和可选上传。
+
+const maxUploadSize = 512 << 20
+
+type FileShareOptions struct {
+	Dir     string `json:"dir"`
+	Listing bool   `json:"listing"`
+	Upload  bool   `json:"upload"`
+
+	Gate    *QuickGate `json:"gate,omitempty"`
+	Inspect bool       `json:"inspect"`
+	QuickLimits
+}
+
+// StartQuickDir: 分享本地目录（通常来自 SelectDirectory）
+func (a *App) StartQuickDir(opts FileShareOptions) QuickResult {
+	if err := opts.QuickLimits.Validate(); err != nil {
+		return QuickResult{Err: err.Error()}
+	}
+	if err := opts.Gate.Validate(); err != nil {
+		return QuickResult{Err: err.Error()}
+	}
+	h, err := newFileShareHandler(opts)
+	if err != nil {
+		return QuickResult{Err: err.Error()}
+	}
+	return a.startQuickFront(h, opts.Gate, opts.Inspect, quickRun{QuickLimits: opts.QuickLimits})
+}
+
+type fileShare struct {
+	root string
+	opts FileShareOptions
+	fs   http.Handler
+}
+
+func newFileShareHandler(opts FileShareOptions) (http.Handler, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("未选择目录")
+	}
+	root, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("目录不可用: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("不是目录: %s", root)
+	}
+	s := &fileShare{root: root, opts: opts}
+	s.fs = http.FileServer(shareFS{dir: http.Dir(root), listing: opts.Listing})
+	return s, nil
+}
+
+func (s *fileShare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if hasDotSegment(r.URL.Path) {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if s.opts.Upload && r.URL.Query().Has("upload") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = io.WriteString(w, uploadPage)
+			return
+		}
+		s.fs.ServeHTTP(w, r)
+	case http.MethodPost:
+		if !s.opts.Upload {
+			http.Error(w, "上传未开启", http.StatusMethodNotAllowed)
+			return
+		}
+		s.upload(w, r)
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// upload: 以 multipart 表单把文件存入请求路径对应的目录，同名文件不覆盖
+func (s *fileShare) upload(w http.ResponseWriter, r *http.Request) {
+	dir := filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "需要 multipart/form-data", http.StatusBadRequest)
+		return
+	}
+	var saved []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "读取上传内容失败: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := filepath.Base(filepath.FromSlash(strings.ReplaceAll(part.FileName(), "\\", "/")))
+		if part.FileName() == "" || name == "." || strings.HasPrefix(name, ".") {
+			part.Close()
+			continue
+		}
+		if err := saveUpload(filepath.Join(dir, name), part); err != nil {
+			part.Close()
+			status := http.StatusInternalServerError
+			if os.IsExist(err) {
+				status = http.StatusConflict
+			}
+			http.Error(w, name+": "+err.Error(), status)
+			return
+		}
+		part.Close()
+		saved = append(saved, name)
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"saved": nonNil(saved)})
+}
+
+func saveUpload(dst string, src io.Reader) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	return f.Close()
+}
+
+// hasDotSegment: 不对外暴露隐藏文件（.git、.env 等）
+func hasDotSegment(p string) bool {
+	for _, seg := range strings.Split(p, "/") {
+		if strings.HasPrefix(seg, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// shareFS: 关闭目录列表时，没有 index.html 的目录按不存在处理
+type shareFS struct {
+	dir     http.Dir
+	listing bool
+}
+
+func (f shareFS) Open(name string) (http.File, error) {
+	file, err := f.dir.Open(name)
+	if err != nil || f.listing {
+		return file, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		index, err := f.dir.Open(path.Join(name, "index.html"))
+		if err != nil {
+			file.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+	return file, nil
+}
+
+const uploadPage = `<!doctype html>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width,initial-scale=1">
+<title>上传文件</title>
+<form method="post" enctype="multipart/form-data">
+  <input type="file" name="file" multiple>
+  <button type="submit">上传</button>
+</form>
+`
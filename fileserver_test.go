@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestShare(t *testing.T, opts FileShareOptions) (http.Handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "a.txt"), []byte("0123456789"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=1"), 0644)
+	_ = os.Mkdir(filepath.Join(dir, "site"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "site", "index.html"), []byte("<h1>hi</h1>"), 0644)
+	opts.Dir = dir
+	h, err := newFileShareHandler(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, dir
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFileShareServe(t *testing.T) {
+	h, _ := newTestShare(t, FileShareOptions{})
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"文件", "/a.txt", http.StatusOK},
+		{"index.html", "/site/", http.StatusOK},
+		{"关闭列表", "/", http.StatusNotFound},
+		{"隐藏文件", "/.env", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, httptest.NewRequest("GET", tt.path, nil))
+			if rec.Code != tt.status {
+				t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.status)
+			}
+		})
+	}
+
+	listing, _ := newTestShare(t, FileShareOptions{Listing: true})
+	if rec := serve(listing, httptest.NewRequest("GET", "/", nil)); rec.Code != http.StatusOK {
+		t.Errorf("listing GET / = %d, want 200", rec.Code)
+	}
+}
+
+func TestFileShareRange(t *testing.T) {
+	h, _ := newTestShare(t, FileShareOptions{})
+	req := httptest.NewRequest("GET", "/a.txt", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	rec := serve(h, req)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "234" {
+		t.Errorf("range = %d %q, want 206 %q", rec.Code, rec.Body.String(), "234")
+	}
+}
+
+func TestFileShareUpload(t *testing.T) {
+	upload := func(h http.Handler, name string) int {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", name)
+		_, _ = io.WriteString(fw, "payload")
+		mw.Close()
+		req := httptest.NewRequest("POST", "/", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return serve(h, req).Code
+	}
+
+	off, _ := newTestShare(t, FileShareOptions{})
+	if code := upload(off, "x.txt"); code != http.StatusMethodNotAllowed {
+		t.Errorf("upload disabled = %d, want 405", code)
+	}
+
+	on, dir := newTestShare(t, FileShareOptions{Upload: true})
+	if code := upload(on, "../../x.txt"); code != http.StatusOK {
+		t.Fatalf("upload = %d, want 200", code)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "x.txt")); err != nil || string(data) != "payload" {
+		t.Errorf("uploaded file = %q, %v", data, err)
+	}
+	if code := upload(on, "a.txt"); code != http.StatusConflict {
+		t.Errorf("overwrite = %d, want 409", code)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ==================== 临时隧道访问控制 ====================
+// 在 cloudflared 与源站之间插入进程内反向代理，按隧道配置：
+//   - 网络白名单：基于 Cloudflare 注入的 Cf-Connecting-Ip / Cf-Ipcountry 头
+//   - 凭据：HTTP Basic 认证或访问令牌，两者都配置时满足其一即可
+// 令牌可通过 Authorization: Bearer、?cftunnel_token= 或同名 Cookie 携带，
+// 手机扫码打开带令牌的链接后会写入 Cookie，后续页面无需再带参数。
+
+const gateTokenParam = "cftunnel_token"
+
+type QuickGate struct {
+	Username       string   `json:"username"`
+	Password       string   `json:"password"`
+	Token          string   `json:"token"`
+	AllowIPs       []string `json:"allow_ips"`       // IP 或 CIDR
+	AllowCountries []string `json:"allow_countries"` // ISO 3166 两位国家代码
+}
+
+func (g *QuickGate) enabled() bool {
+	return g != nil && (g.basic() || g.Token != "" || len(g.AllowIPs) > 0 || len(g.AllowCountries) > 0)
+}
+
+func (g *QuickGate) basic() bool {
+	return g.Username != "" || g.Password != ""
+}
+
+func (g *QuickGate) Validate() error {
+	if g == nil {
+		return nil
+	}
+	if g.basic() && (g.Username == "" || g.Password == "") {
+		return errors.New("Basic 认证需要同时设置用户名和密码")
+	}
+	if strings.Contains(g.Username, ":") {
+		return errors.New("用户名不能包含冒号")
+	}
+	if _, err := parseAllowIPs(g.AllowIPs); err != nil {
+		return err
+	}
+	for _, c := range g.AllowCountries {
+		if len(strings.TrimSpace(c)) != 2 {
+			return fmt.Errorf("国家代码无效: %q", c)
+		}
+	}
+	return nil
+}
+
+func parseAllowIPs(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("IP 无效: %q", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("网段无效: %q", s)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// wrap: 调用前须已通过 Validate
+func (g *QuickGate) wrap(next http.Handler) http.Handler {
+	nets, _ := parseAllowIPs(g.AllowIPs)
+	countries := make(map[string]bool)
+	for _, c := range g.AllowCountries {
+		countries[strings.ToUpper(strings.TrimSpace(c))] = true
+	}
+	needCreds := g.basic() || g.Token != ""
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(nets) > 0 && !ipAllowed(clientIP(r), nets) {
+			markGateRejected(r)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if len(countries) > 0 && !countries[strings.ToUpper(r.Header.Get("Cf-Ipcountry"))] {
+			markGateRejected(r)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if needCreds && !g.authorized(w, r) {
+			markGateRejected(r)
+			if g.basic() {
+				w.Header().Set("WWW-Authenticate", `Basic realm="cftunnel", charset="UTF-8"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized: 校验通过后剥离网关自己的凭据，避免泄露给源站
+func (g *QuickGate) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if g.basic() {
+		if u, p, ok := r.BasicAuth(); ok && secureEqual(u, g.Username) && secureEqual(p, g.Password) {
+			r.Header.Del("Authorization")
+			return true
+		}
+	}
+	if g.Token == "" {
+		return false
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") && secureEqual(strings.TrimPrefix(auth, "Bearer "), g.Token) {
+		r.Header.Del("Authorization")
+		return true
+	}
+	q := r.URL.Query()
+	if tok := q.Get(gateTokenParam); tok != "" && secureEqual(tok, g.Token) {
+		http.SetCookie(w, &http.Cookie{Name: gateTokenParam, Value: tok, Path: "/", HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode})
+		q.Del(gateTokenParam)
+		r.URL.RawQuery = q.Encode()
+		return true
+	}
+	if c, err := r.Cookie(gateTokenParam); err == nil && secureEqual(c.Value, g.Token) {
+		removeCookie(r.Header, gateTokenParam)
+		return true
+	}
+	return false
+}
+
+// removeCookie: 从 Cookie 头中去掉指定名称的项，保留其余 Cookie
+func removeCookie(h http.Header, name string) {
+	var kept []string
+	for _, line := range h.Values("Cookie") {
+		for _, part := range strings.Split(line, ";") {
+			part = strings.TrimSpace(part)
+			if n, _, _ := strings.Cut(part, "="); part == "" || strings.TrimSpace(n) == name {
+				continue
+			}
+			kept = append(kept, part)
+		}
+	}
+	h.Del("Cookie")
+	if len(kept) > 0 {
+		h.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
+
+// redactedValue: 检查器记录中替换网关凭据的占位值
+const redactedValue = "REDACTED"
+
+// redact: 在记录用的请求头副本中隐去网关凭据，返回隐去令牌参数后的请求路径
+func (g *QuickGate) redact(h http.Header, u *url.URL) string {
+	auth := h.Get("Authorization")
+	scheme, _, _ := strings.Cut(auth, " ")
+	if (g.basic() && strings.EqualFold(scheme, "Basic")) || (g.Token != "" && strings.EqualFold(scheme, "Bearer")) {
+		h.Set("Authorization", scheme+" "+redactedValue)
+	}
+	if g.Token == "" {
+		return u.RequestURI()
+	}
+	if _, err := (&http.Request{Header: h}).Cookie(gateTokenParam); err == nil {
+		removeCookie(h, gateTokenParam)
+		h.Add("Cookie", gateTokenParam+"="+redactedValue)
+	}
+	q := u.Query()
+	if !q.Has(gateTokenParam) {
+		return u.RequestURI()
+	}
+	q.Set(gateTokenParam, redactedValue)
+	redacted := *u
+	redacted.RawQuery = q.Encode()
+	return redacted.RequestURI()
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// clientIP: 经 Cloudflare 转发时取 Cf-Connecting-Ip，本机直连时取对端地址
+func clientIP(r *http.Request) net.IP {
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("Cf-Connecting-Ip"))); ip != nil {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func ipAllowed(ip net.IP, nets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
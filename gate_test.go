@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQuickGate(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("gate credentials leaked to origin")
+		}
+		if r.URL.Query().Has(gateTokenParam) {
+			t.Errorf("gate token param leaked to origin")
+		}
+		if _, err := r.Cookie(gateTokenParam); err == nil {
+			t.Errorf("gate token cookie leaked to origin")
+		}
+	})
+	gate := &QuickGate{Username: "admin", Password: "pw", Token: "tok", AllowCountries: []string{"cn", "HK"}}
+	if err := gate.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	h := gate.wrap(ok)
+
+	tests := []struct {
+		name    string
+		path    string
+		country string
+		setup   func(r *http.Request)
+		status  int
+	}{
+		{"无凭据", "/", "CN", nil, http.StatusUnauthorized},
+		{"Basic 正确", "/", "CN", func(r *http.Request) { r.SetBasicAuth("admin", "pw") }, http.StatusOK},
+		{"Basic 错误", "/", "CN", func(r *http.Request) { r.SetBasicAuth("admin", "x") }, http.StatusUnauthorized},
+		{"Bearer", "/", "HK", func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok") }, http.StatusOK},
+		{"查询参数令牌", "/?cftunnel_token=tok&a=1", "CN", nil, http.StatusOK},
+		{"Cookie 令牌", "/", "CN", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: gateTokenParam, Value: "tok"}) }, http.StatusOK},
+		{"Cookie 令牌与其他 Cookie", "/", "CN", func(r *http.Request) {
+			r.Header.Set("Cookie", "session=abc; "+gateTokenParam+"=tok; theme=dark")
+		}, http.StatusOK},
+		{"国家不在白名单", "/", "US", func(r *http.Request) { r.SetBasicAuth("admin", "pw") }, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Cf-Ipcountry", tt.country)
+			if tt.setup != nil {
+				tt.setup(req)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestQuickGateAllowIPs(t *testing.T) {
+	gate := &QuickGate{AllowIPs: []string{"203.0.113.0/24", "2001:db8::1"}}
+	if err := gate.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	h := gate.wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for ip, want := range map[string]int{
+		"203.0.113.9":  http.StatusOK,
+		"2001:db8::1":  http.StatusOK,
+		"198.51.100.1": http.StatusForbidden,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Cf-Connecting-Ip", ip)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", ip, rec.Code, want)
+		}
+	}
+}
+
+func TestQuickGateValidate(t *testing.T) {
+	bad := []*QuickGate{
+		{Username: "admin"},
+		{Username: "a:b", Password: "x"},
+		{AllowIPs: []string{"10.0.0.0/33"}},
+		{AllowCountries: []string{"CHN"}},
+	}
+	for _, g := range bad {
+		if g.Validate() == nil {
+			t.Errorf("Validate(%+v) = nil, want error", *g)
+		}
+	}
+	spec := QuickSpec{Scheme: "tcp", Port: 22, Gate: &QuickGate{Token: "x"}}
+	spec.normalize()
+	if spec.Validate() == nil {
+		t.Errorf("gate on tcp origin should be rejected")
+	}
+}
+
+func TestRemoveCookie(t *testing.T) {
+	h := http.Header{}
+	h.Add("Cookie", "session=abc; "+gateTokenParam+"=tok")
+	h.Add("Cookie", "theme=dark")
+	removeCookie(h, gateTokenParam)
+	if got := h.Get("Cookie"); got != "session=abc; theme=dark" || len(h.Values("Cookie")) != 1 {
+		t.Errorf("Cookie = %q", h.Values("Cookie"))
+	}
+	h = http.Header{"Cookie": {gateTokenParam + "=tok"}}
+	removeCookie(h, gateTokenParam)
+	if _, ok := h["Cookie"]; ok {
+		t.Errorf("empty Cookie header kept: %q", h.Values("Cookie"))
+	}
+}
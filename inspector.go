@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ==================== 请求检查器 ====================
+// 类似 ngrok inspector：在前置代理里记录经过临时隧道的请求与响应，
+// 保存在内存环形缓冲区中，并通过 quick:request 事件实时推送给前端。
+
+const (
+	inspectorCapacity = 200
+	inspectorBodyCap  = 64 << 10
+)
+
+type CapturedRequest struct {
+	ID       int64     `json:"id"`
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Host     string    `json:"host"`
+	RemoteIP string    `json:"remote_ip"`
+
+	ReqHeaders       http.Header `json:"req_headers"`
+	ReqBody          []byte      `json:"req_body"`
+	ReqBodySize      int64       `json:"req_body_size"`
+	ReqBodyTruncated bool        `json:"req_body_truncated"`
+
+	Status            int         `json:"status"`
+	RespHeaders       http.Header `json:"resp_headers"`
+	RespBody          []byte      `json:"resp_body"`
+	RespBodySize      int64       `json:"resp_body_size"`
+	RespBodyTruncated bool        `json:"resp_body_truncated"`
+
+	DurationMS   int64 `json:"duration_ms"`
+	ReplayOf     int64 `json:"replay_of,omitempty"`     // 重放记录指向原始请求
+	GateRejected bool  `json:"gate_rejected,omitempty"` // 被访问控制拦截，未到达源站
+}
+
+// gateRejectKey: 检查器通过请求上下文得知访问控制是否拦截了该请求
+type gateRejectKey struct{}
+
+func markGateRejected(r *http.Request) {
+	if flag, ok := r.Context().Value(gateRejectKey{}).(*bool); ok {
+		*flag = true
+	}
+}
+
+type requestLog struct {
+	mu      sync.Mutex
+	max     int
+	nextID  int64
+	entries []CapturedRequest
+}
+
+func newRequestLog(max int) *requestLog {
+	return &requestLog{max: max}
+}
+
+func (l *requestLog) add(c CapturedRequest) CapturedRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.nextID++
+	c.ID = l.nextID
+	l.entries = append(l.entries, c)
+	if len(l.entries) > l.max {
+		l.entries = append([]CapturedRequest(nil), l.entries[len(l.entries)-l.max:]...)
+	}
+	return c
+}
+
+func (l *requestLog) list() []CapturedRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]CapturedRequest{}, l.entries...)
+}
+
+func (l *requestLog) get(id int64) (CapturedRequest, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, c := range l.entries {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return CapturedRequest{}, false
+}
+
+func (l *requestLog) clear() {
+	l.mu.Lock()
+	l.entries = nil
+	l.mu.Unlock()
+}
+
+// GetQuickRequests: 按时间顺序返回已记录的请求
+func (a *App) GetQuickRequests() []CapturedRequest {
+	return a.requests.list()
+}
+
+func (a *App) ClearQuickRequests() {
+	a.requests.clear()
+}
+
+// inspect: 记录请求和响应（正文按上限截断），不影响实际转发；
+// gate 非空时记录前隐去其 Basic 密码、访问令牌（请求头、查询参数与 Cookie）
+func (a *App) inspect(next http.Handler, gate *QuickGate) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		c := CapturedRequest{
+			Time:       start,
+			Method:     r.Method,
+			Path:       r.URL.RequestURI(),
+			Host:       r.Host,
+			RemoteIP:   clientIP(r).String(),
+			ReqHeaders: r.Header.Clone(),
+		}
+		if gate.enabled() {
+			c.Path = gate.redact(c.ReqHeaders, r.URL)
+		}
+
+		reqBody := &capBuffer{limit: inspectorBodyCap}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = readCloser{io.TeeReader(r.Body, reqBody), r.Body}
+		}
+		rec := &recordingWriter{ResponseWriter: w, body: &capBuffer{limit: inspectorBodyCap}}
+
+		rejected := false
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), gateRejectKey{}, &rejected)))
+		c.GateRejected = rejected
+
+		c.ReqBody, c.ReqBodySize, c.ReqBodyTruncated = reqBody.result()
+		c.Status = rec.status
+		if c.Status == 0 {
+			c.Status = http.StatusOK
+		}
+		c.RespHeaders = w.Header().Clone()
+		c.RespBody, c.RespBodySize, c.RespBodyTruncated = rec.body.result()
+		c.DurationMS = time.Since(start).Milliseconds()
+
+		a.emit("quick:request", a.requests.add(c))
+	})
+}
+
+// capBuffer: 只保留前 limit 字节，但统计总长度；请求体可能在代理的传输协程中写入，故加锁
+type capBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	limit int
+	total int64
+}
+
+func (b *capBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.total += int64(len(p))
+	if room := b.limit - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *capBuffer) result() ([]byte, int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...), b.total, b.total > int64(b.buf.Len())
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   *capBuffer
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	_, _ = w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush: 保证 SSE 等流式响应经过检查器时仍能及时下发
+func (w *recordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
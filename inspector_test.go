@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInspectRecords(t *testing.T) {
+	a := NewApp()
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Echo", "1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(append([]byte("got:"), body...))
+	})
+	h := a.inspect(origin, nil)
+
+	req := httptest.NewRequest("POST", "/hook?x=1", strings.NewReader(`{"event":"push"}`))
+	req.Header.Set("Cf-Connecting-Ip", "203.0.113.7")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Body.String() != `got:{"event":"push"}` {
+		t.Fatalf("proxied body = %q", rec.Body.String())
+	}
+	got := a.GetQuickRequests()
+	if len(got) != 1 {
+		t.Fatalf("captured %d requests, want 1", len(got))
+	}
+	c := got[0]
+	if c.ID != 1 || c.Method != "POST" || c.Path != "/hook?x=1" || c.RemoteIP != "203.0.113.7" {
+		t.Errorf("captured request = %+v", c)
+	}
+	if string(c.ReqBody) != `{"event":"push"}` || c.Status != http.StatusCreated || c.RespHeaders.Get("X-Echo") != "1" {
+		t.Errorf("captured exchange = %q %d %v", c.ReqBody, c.Status, c.RespHeaders)
+	}
+}
+
+func TestInspectBodyCap(t *testing.T) {
+	a := NewApp()
+	h := a.inspect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+	}), nil)
+	big := strings.Repeat("a", inspectorBodyCap+10)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/", strings.NewReader(big)))
+
+	c := a.GetQuickRequests()[0]
+	if len(c.ReqBody) != inspectorBodyCap || c.ReqBodySize != int64(len(big)) || !c.ReqBodyTruncated {
+		t.Errorf("body len=%d size=%d truncated=%v", len(c.ReqBody), c.ReqBodySize, c.ReqBodyTruncated)
+	}
+}
+
+func TestInspectRedactsGateCredentials(t *testing.T) {
+	a := NewApp()
+	gate := &QuickGate{Username: "admin", Password: "pw", Token: "tok"}
+	h := a.wrapQuickHandler(http.NotFoundHandler(), gate, true)
+
+	req := httptest.NewRequest("GET", "/a?cftunnel_token=tok&x=1", nil)
+	req.Header.Set("Cookie", "session=abc; cftunnel_token=tok")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest("GET", "/b", nil)
+	req.SetBasicAuth("admin", "wrong-pw")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := a.GetQuickRequests()
+	if len(got) != 2 {
+		t.Fatalf("captured %d requests", len(got))
+	}
+	for _, c := range got {
+		if strings.Contains(c.Path, "=tok") || strings.Contains(c.ReqHeaders.Get("Cookie"), "=tok") {
+			t.Errorf("token captured: %s %v", c.Path, c.ReqHeaders)
+		}
+	}
+	if got[0].Path != "/a?cftunnel_token=REDACTED&x=1" || got[0].ReqHeaders.Get("Cookie") == "" {
+		t.Errorf("capture = %s %v", got[0].Path, got[0].ReqHeaders)
+	}
+	if auth := got[1].ReqHeaders.Get("Authorization"); auth != "Basic REDACTED" {
+		t.Errorf("Authorization captured as %q", auth)
+	}
+}
+
+func TestRequestLogRing(t *testing.T) {
+	l := newRequestLog(3)
+	for i := 0; i < 5; i++ {
+		l.add(CapturedRequest{})
+	}
+	got := l.list()
+	if len(got) != 3 || got[0].ID != 3 || got[2].ID != 5 {
+		t.Errorf("ring = %+v", got)
+	}
+	if _, ok := l.get(1); ok {
+		t.Errorf("evicted entry still retrievable")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 单实例锁：先抢占系统级互斥量（Windows 命名互斥量，其他平台 flock），抢到的实例
+// 才在回环地址监听并把端口与令牌写入状态目录；后续启动的实例连上去转交命令行参数后
+// 直接退出，避免互相杀掉隧道进程。互斥量随进程退出自动释放，不会因异常退出而残留。
+
+var errInstanceRunning = errors.New("已有实例在运行")
+
+type instanceInfo struct {
+	PID   int    `json:"pid"`
+	Port  int    `json:"port"`
+	Token string `json:"token"`
+}
+
+type instanceMessage struct {
+	Token string   `json:"token"`
+	Args  []string `json:"args"`
+}
+
+// instanceHandoffWait: 未抢到互斥量时等待首个实例写出监听信息的时间
+const instanceHandoffWait = 3 * time.Second
+
+type instanceLock struct {
+	ln      net.Listener
+	path    string
+	token   string
+	release func() // 释放系统级互斥量
+
+	mu      sync.Mutex
+	handler func(args []string)
+}
+
+func instanceLockPath() string {
+	return filepath.Join(stateDir(), "app.lock")
+}
+
+// acquireInstanceLock: 获取单实例锁；若已有实例在运行，则把 args 转交给它并返回 errInstanceRunning。
+// 互斥量被占用时即使转交失败也返回 errInstanceRunning，绝不启动第二个实例。
+func acquireInstanceLock(args []string) (*instanceLock, error) {
+	path := instanceLockPath()
+	_ = os.MkdirAll(filepath.Dir(path), 0700)
+	release, err := lockInstanceMutex(path)
+	if err == errInstanceRunning {
+		// 首个实例可能刚抢到互斥量还没写出监听信息，稍等重试；
+		// 互斥量存在时锁文件里若是上次异常退出的残留，也会在首个实例写入后被覆盖
+		deadline := time.Now().Add(instanceHandoffWait)
+		for {
+			if data, rerr := os.ReadFile(path); rerr == nil {
+				var info instanceInfo
+				if json.Unmarshal(data, &info) == nil && info.Port > 0 && handoffToInstance(info, args) == nil {
+					return nil, errInstanceRunning
+				}
+			}
+			if time.Now().After(deadline) {
+				return nil, errInstanceRunning
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		release()
+		return nil, err
+	}
+	token, err := randomHex(16)
+	if err != nil {
+		ln.Close()
+		release()
+		return nil, err
+	}
+	l := &instanceLock{ln: ln, path: path, token: token, release: release}
+
+	info := instanceInfo{
+		PID:   os.Getpid(),
+		Port:  ln.Addr().(*net.TCPAddr).Port,
+		Token: l.token,
+	}
+	data, _ := json.Marshal(info)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		ln.Close()
+		release()
+		return nil, err
+	}
+
+	go l.serve()
+	return l, nil
+}
+
+func handoffToInstance(info instanceInfo, args []string) error {
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(info.Port), time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+
+	if args == nil {
+		args = []string{}
+	}
+	if err := json.NewEncoder(conn).Encode(instanceMessage{Token: info.Token, Args: args}); err != nil {
+		return err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if reply != "ok\n" {
+		return errors.New("实例拒绝了转交请求")
+	}
+	return nil
+}
+
+// OnHandoff: 设置收到第二个实例参数时的回调
+func (l *instanceLock) OnHandoff(fn func(args []string)) {
+	l.mu.Lock()
+	l.handler = fn
+	l.mu.Unlock()
+}
+
+func (l *instanceLock) serve() {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			return
+		}
+		go l.handle(conn)
+	}
+}
+
+func (l *instanceLock) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+
+	var msg instanceMessage
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&msg); err != nil {
+		return
+	}
+	if msg.Token != l.token {
+		_, _ = conn.Write([]byte("denied\n"))
+		return
+	}
+	_, _ = conn.Write([]byte("ok\n"))
+
+	l.mu.Lock()
+	fn := l.handler
+	l.mu.Unlock()
+	if fn != nil {
+		fn(msg.Args)
+	}
+}
+
+// Release: 删除锁文件（仅当锁文件仍属于本实例时）后释放互斥量
+func (l *instanceLock) Release() {
+	l.ln.Close()
+	defer l.release()
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return
+	}
+	var info instanceInfo
+	if json.Unmarshal(data, &info) == nil && info.Token == l.token {
+		_ = os.Remove(l.path)
+	}
+}
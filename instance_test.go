@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInstanceLockHandoff(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	first, err := acquireInstanceLock(nil)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	defer first.Release()
+
+	got := make(chan []string, 1)
+	first.OnHandoff(func(args []string) { got <- args })
+
+	if _, err := acquireInstanceLock([]string{"--page", "quick"}); err != errInstanceRunning {
+		t.Fatalf("second acquire err = %v, want errInstanceRunning", err)
+	}
+	select {
+	case args := <-got:
+		if len(args) != 2 || args[1] != "quick" {
+			t.Errorf("handoff args = %v", args)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handoff not received")
+	}
+}
+
+func TestInstanceLockStale(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	_ = os.MkdirAll(stateDir(), 0700)
+	// 指向一个无人监听的端口，模拟上次异常退出残留的锁文件
+	stale := `{"pid":1,"port":1,"token":"x"}`
+	if err := os.WriteFile(instanceLockPath(), []byte(stale), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := acquireInstanceLock(nil)
+	if err != nil {
+		t.Fatalf("acquire over stale lock: %v", err)
+	}
+	l.Release()
+	if _, err := os.Stat(instanceLockPath()); !os.IsNotExist(err) {
+		t.Errorf("lock file should be removed after Release")
+	}
+}
+
+func TestInstanceLockConcurrent(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const n = 8
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		locks []*instanceLock
+	)
+	handoffs := make(chan []string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := acquireInstanceLock([]string{"x"})
+			if err == errInstanceRunning {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			l.OnHandoff(func(args []string) { handoffs <- args })
+			mu.Lock()
+			locks = append(locks, l)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(locks) != 1 {
+		t.Fatalf("%d instances acquired the lock", len(locks))
+	}
+	locks[0].Release()
+
+	// 释放后可以重新获取
+	l, err := acquireInstanceLock(nil)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	l.Release()
+}
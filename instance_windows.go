@@ -0,0 +1,29 @@
+//go:build windows
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockInstanceMutex: 按状态目录命名的互斥量，同一用户会话内唯一，进程退出时系统自动回收
+func lockInstanceMutex(lockPath string) (func(), error) {
+	sum := sha256.Sum256([]byte(strings.ToLower(lockPath)))
+	name, err := windows.UTF16PtrFromString(`Local\cftunnel-app-` + hex.EncodeToString(sum[:8]))
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.CreateMutex(nil, false, name)
+	if err == windows.ERROR_ALREADY_EXISTS {
+		windows.CloseHandle(h)
+		return nil, errInstanceRunning
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() { windows.CloseHandle(h) }, nil
+}
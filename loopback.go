@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// startLoopbackServer: 在 127.0.0.1 的随机端口上启动内置 HTTP 服务，返回端口与关闭函数
+func startLoopbackServer(h http.Handler) (int, func(), error) {
+	addr, stop, err := serveLoopback(0, h)
+	if err != nil {
+		return 0, nil, err
+	}
+	_, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+	return p, stop, nil
+}
+
+// serveLoopback: 在 127.0.0.1 的指定端口（0 为随机）上启动 HTTP 服务，返回实际地址与关闭函数
+func serveLoopback(port int, h http.Handler) (string, func(), error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		return "", nil, err
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 30 * time.Second}
+	go func() { _ = srv.Serve(ln) }()
+
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}
+	return ln.Addr().String(), stop, nil
+}
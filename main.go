@@ -2,6 +2,7 @@ package main
 
 import (
 	"embed"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -13,9 +14,27 @@ import (
 var assets embed.FS
 
 func main() {
+	// 带子命令时以命令行模式运行，不打开窗口
+	if len(os.Args) > 1 && isCLICommand(os.Args[1]) {
+		attachConsole()
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
+	// 单实例：已有窗口在运行时，把参数交给它后退出
+	lock, err := acquireInstanceLock(os.Args[1:])
+	if err == errInstanceRunning {
+		return
+	}
+	if lock != nil {
+		defer lock.Release()
+	}
+
 	app := NewApp()
+	if lock != nil {
+		lock.OnHandoff(app.onSecondInstance)
+	}
 
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:            "cftunnel",
 		Width:            960,
 		Height:           640,
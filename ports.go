@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ==================== 本地端口发现 ====================
+// 列出本机正在监听的 TCP/UDP 端口及所属进程，供添加临时隧道、路由、中继规则时选择。
+// Linux 读取 /proc/net 与 /proc/<pid>/fd，其他平台解析 netstat 输出（Windows 另用 tasklist 取进程名）。
+// 已被路由、中继规则或临时隧道使用的端口会在 UsedBy 中标出。
+
+type ListeningPort struct {
+	Proto   string   `json:"proto"` // tcp / udp
+	Address string   `json:"address"`
+	Port    int      `json:"port"`
+	PID     int      `json:"pid,omitempty"`
+	Process string   `json:"process,omitempty"`
+	UsedBy  []string `json:"used_by"` // 如 "路由 web"、"中继 mc"、"临时隧道"
+}
+
+type ListeningPortsResult struct {
+	Ports []ListeningPort `json:"ports"`
+	Err   string          `json:"err,omitempty"`
+}
+
+// ListListeningPorts: 按端口排序，同一协议同一端口只保留一条（IPv4/IPv6 合并）
+func (a *App) ListListeningPorts() ListeningPortsResult {
+	socks, err := listListeningSockets()
+	if err != nil {
+		return ListeningPortsResult{Ports: []ListeningPort{}, Err: "读取监听端口失败: " + err.Error()}
+	}
+	return ListeningPortsResult{Ports: mergeListeningPorts(socks, a.portUsage())}
+}
+
+// portUsage: 以 "协议/端口" 为键收集已被占用的本地端口；读取失败的部分直接跳过
+func (a *App) portUsage() map[string][]string {
+	usage := map[string][]string{}
+	add := func(proto string, port int, who string) {
+		if port > 0 {
+			key := proto + "/" + strconv.Itoa(port)
+			usage[key] = append(usage[key], who)
+		}
+	}
+	if routes, err := listRoutes(); err == nil {
+		for _, r := range routes {
+			if port, err := localServicePort(r.Service); err == nil {
+				add("tcp", port, "路由 "+r.Name)
+			}
+		}
+	}
+	if rules, err := (currentStateReader{}).relayRules(); err == nil {
+		for _, r := range rules {
+			proto := strings.ToLower(r.Proto)
+			if proto == "http" {
+				proto = "tcp"
+			}
+			add(proto, r.LocalPort, "中继 "+r.Name)
+		}
+	}
+	a.quickMu.Lock()
+	quickPort := 0
+	if a.quickCmd != nil {
+		quickPort = a.quickPort
+	}
+	a.quickMu.Unlock()
+	add("tcp", quickPort, "临时隧道")
+	return usage
+}
+
+func mergeListeningPorts(socks []ListeningPort, usage map[string][]string) []ListeningPort {
+	seen := map[string]int{}
+	ports := []ListeningPort{}
+	for _, s := range socks {
+		key := s.Proto + "/" + strconv.Itoa(s.Port)
+		if i, ok := seen[key]; ok {
+			if ports[i].Process == "" {
+				ports[i].PID, ports[i].Process = s.PID, s.Process
+			}
+			continue
+		}
+		seen[key] = len(ports)
+		s.UsedBy = nonNil(usage[key])
+		ports = append(ports, s)
+	}
+	sort.SliceStable(ports, func(i, j int) bool {
+		if ports[i].Port != ports[j].Port {
+			return ports[i].Port < ports[j].Port
+		}
+		return ports[i].Proto < ports[j].Proto
+	})
+	return ports
+}
+
+// ---------- /proc/net 解析 ----------
+
+// procSocket: /proc/net/{tcp,udp}[6] 中的一行，inode 用于关联进程
+type procSocket struct {
+	ListeningPort
+	inode string
+}
+
+const (
+	procTCPListen = "0A"
+	procUDPUnconn = "07"
+)
+
+// parseProcNet: 只保留 TCP LISTEN 与未连接的 UDP 套接字
+func parseProcNet(r io.Reader, proto string) ([]procSocket, error) {
+	var socks []procSocket
+	sc := bufio.NewScanner(r)
+	for first := true; sc.Scan(); first = false {
+		fields := strings.Fields(sc.Text())
+		if first || len(fields) < 10 {
+			continue
+		}
+		local, remote, state := fields[1], fields[2], fields[3]
+		switch {
+		case proto == "tcp" && state != procTCPListen:
+			continue
+		case proto == "udp" && (state != procUDPUnconn || !strings.HasSuffix(remote, ":0000")):
+			continue
+		}
+		addr, port, err := parseProcAddr(local)
+		if err != nil {
+			return nil, err
+		}
+		socks = append(socks, procSocket{
+			ListeningPort: ListeningPort{Proto: proto, Address: addr, Port: port},
+			inode:         fields[9],
+		})
+	}
+	return socks, sc.Err()
+}
+
+// parseProcAddr: "0100007F:0BB8" -> 127.0.0.1, 3000；地址按 32 位字小端存放
+func parseProcAddr(s string) (string, int, error) {
+	hexIP, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("地址格式无效: %s", s)
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("端口格式无效: %s", s)
+	}
+	raw, err := hex.DecodeString(hexIP)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", 0, fmt.Errorf("地址格式无效: %s", s)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return ip.String(), int(port), nil
+}
+
+// ---------- netstat 解析 ----------
+
+// parseNetstat: 兼容 Windows "netstat -ano"（地址:端口，末列 PID）与 BSD/macOS "netstat -an"（地址.端口）
+func parseNetstat(output string) []ListeningPort {
+	var ports []ListeningPort
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		proto := strings.ToLower(fields[0])
+		switch {
+		case strings.HasPrefix(proto, "tcp"):
+			proto = "tcp"
+		case strings.HasPrefix(proto, "udp"):
+			proto = "udp"
+		default:
+			continue
+		}
+		p := ListeningPort{Proto: proto}
+		var local, state string
+		if addr, port, ok := splitNetstatAddr(fields[1], ":"); ok {
+			// Windows: Proto Local Foreign [State] PID
+			local = fields[1]
+			p.Address, p.Port = addr, port
+			p.PID, _ = strconv.Atoi(fields[len(fields)-1])
+			if proto == "tcp" && len(fields) >= 5 {
+				state = fields[3]
+			}
+		} else if len(fields) >= 5 {
+			// BSD: Proto Recv-Q Send-Q Local Foreign [State]
+			local = fields[3]
+			if p.Address, p.Port, ok = splitNetstatAddr(local, "."); !ok {
+				continue
+			}
+			if len(fields) >= 6 {
+				state = fields[5]
+			}
+		}
+		if local == "" || (proto == "tcp" && state != "LISTENING" && state != "LISTEN") {
+			continue
+		}
+		ports = append(ports, p)
+	}
+	return ports
+}
+
+// splitNetstatAddr: 按最后一个分隔符拆出端口，[::] 与 * 统一为通配地址
+func splitNetstatAddr(s, sep string) (string, int, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(s[i+1:])
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, false
+	}
+	addr := strings.Trim(s[:i], "[]")
+	if j := strings.Index(addr, "%"); j >= 0 {
+		addr = addr[:j]
+	}
+	if addr == "*" {
+		addr = "0.0.0.0"
+	}
+	return addr, port, true
+}
+
+// parseTasklist: 解析 "tasklist /FO CSV /NH"，返回 PID 到进程名的映射
+func parseTasklist(output string) map[int]string {
+	names := map[int]string{}
+	r := csv.NewReader(strings.NewReader(output))
+	r.FieldsPerRecord = -1
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || len(rec) < 2 {
+			continue
+		}
+		if pid, err := strconv.Atoi(rec[1]); err == nil {
+			names[pid] = rec[0]
+		}
+	}
+	return names
+}
+
+// isLoopbackHost: 源站是否在本机（localhost、回环或通配地址）
+func isLoopbackHost(h string) bool {
+	h = strings.Trim(h, "[]")
+	if strings.EqualFold(h, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(h)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
@@ -0,0 +1,77 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// listListeningSockets: 读取 /proc/net，再遍历 /proc/<pid>/fd 按 socket inode 找到所属进程；
+// 无权限读取的进程（其他用户）只显示端口不显示进程名
+func listListeningSockets() ([]ListeningPort, error) {
+	var socks []procSocket
+	for _, f := range []struct{ file, proto string }{
+		{"tcp", "tcp"}, {"tcp6", "tcp"}, {"udp", "udp"}, {"udp6", "udp"},
+	} {
+		fh, err := os.Open(filepath.Join("/proc/net", f.file))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // 未启用 IPv6
+			}
+			return nil, err
+		}
+		s, err := parseProcNet(fh, f.proto)
+		fh.Close()
+		if err != nil {
+			return nil, err
+		}
+		socks = append(socks, s...)
+	}
+
+	owners := socketOwners()
+	ports := make([]ListeningPort, len(socks))
+	for i, s := range socks {
+		if pid, ok := owners[s.inode]; ok {
+			s.PID = pid
+			s.Process = procName(pid)
+		}
+		ports[i] = s.ListeningPort
+	}
+	return ports, nil
+}
+
+// socketOwners: inode -> pid
+func socketOwners() map[string]int {
+	owners := map[string]int{}
+	entries, _ := os.ReadDir("/proc")
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", e.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			owners[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] = pid
+		}
+	}
+	return owners
+}
+
+func procName(pid int) string {
+	b, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
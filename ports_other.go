@@ -0,0 +1,39 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// listListeningSockets: 解析 netstat 输出；Windows 通过 tasklist 补全进程名
+func listListeningSockets() ([]ListeningPort, error) {
+	args := []string{"-an"}
+	if runtime.GOOS == "windows" {
+		args = []string{"-ano"}
+	}
+	out, err := runHidden("netstat", args...)
+	if err != nil {
+		return nil, fmt.Errorf("netstat: %v: %s", err, strings.TrimSpace(out))
+	}
+	ports := parseNetstat(out)
+	if runtime.GOOS == "windows" {
+		if list, err := runHidden("tasklist", "/FO", "CSV", "/NH"); err == nil {
+			names := parseTasklist(list)
+			for i := range ports {
+				ports[i].Process = names[ports[i].PID]
+			}
+		}
+	}
+	return ports, nil
+}
+
+func runHidden(name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	hideWindow(cmd)
+	out, err := cmd.Output()
+	return string(out), err
+}
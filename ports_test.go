@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProcNet(t *testing.T) {
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:0BB8 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 41234 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:0BB8 0100007F:D2C4 01 00000000:00000000 00:00000000 00000000  1000        0 41299 1 0000000000000000 20 4 30 10 -1
+`
+	socks, err := parseProcNet(strings.NewReader(tcp), "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(socks) != 1 || socks[0].Address != "127.0.0.1" || socks[0].Port != 3000 || socks[0].inode != "41234" {
+		t.Errorf("tcp = %+v", socks)
+	}
+
+	udp6 := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  12: 00000000000000000000000000000000:14E9 00000000000000000000000000000000:0000 07 00000000:00000000 00:00000000 00000000   105        0 18012 2 0000000000000000 0
+`
+	socks, err = parseProcNet(strings.NewReader(udp6), "udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(socks) != 1 || socks[0].Address != "::" || socks[0].Port != 5353 {
+		t.Errorf("udp6 = %+v", socks)
+	}
+
+	if addr, _, _ := parseProcAddr("00000000000000000000000001000000:0050"); addr != "::1" {
+		t.Errorf("::1 parsed as %s", addr)
+	}
+}
+
+func TestParseNetstat(t *testing.T) {
+	windows := `
+活动连接
+
+  协议  本地地址          外部地址        状态           PID
+  TCP    0.0.0.0:135            0.0.0.0:0              LISTENING       1088
+  TCP    127.0.0.1:3000         127.0.0.1:51234        ESTABLISHED     4321
+  TCP    [::]:445               [::]:0                 LISTENING       4
+  UDP    0.0.0.0:5353           *:*                                    2345
+`
+	got := parseNetstat(windows)
+	want := []ListeningPort{
+		{Proto: "tcp", Address: "0.0.0.0", Port: 135, PID: 1088},
+		{Proto: "tcp", Address: "::", Port: 445, PID: 4},
+		{Proto: "udp", Address: "0.0.0.0", Port: 5353, PID: 2345},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("windows = %+v", got)
+	}
+
+	bsd := `Active Internet connections (including servers)
+Proto Recv-Q Send-Q  Local Address          Foreign Address        (state)
+tcp4       0      0  127.0.0.1.8080         *.*                    LISTEN
+tcp46      0      0  *.3000                 *.*                    LISTEN
+tcp4       0      0  192.168.1.5.52100      17.57.146.20.443       ESTABLISHED
+udp4       0      0  *.5353                 *.*
+`
+	got = parseNetstat(bsd)
+	want = []ListeningPort{
+		{Proto: "tcp", Address: "127.0.0.1", Port: 8080},
+		{Proto: "tcp", Address: "0.0.0.0", Port: 3000},
+		{Proto: "udp", Address: "0.0.0.0", Port: 5353},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bsd = %+v", got)
+	}
+
+	names := parseTasklist(`"System","4","Services","0","144 K"
+"node.exe","1088","Console","1","52,120 K"
+`)
+	if names[1088] != "node.exe" || names[4] != "System" {
+		t.Errorf("tasklist = %v", names)
+	}
+}
+
+func TestMergeListeningPorts(t *testing.T) {
+	socks := []ListeningPort{
+		{Proto: "tcp", Address: "::", Port: 8080},
+		{Proto: "tcp", Address: "0.0.0.0", Port: 8080, PID: 7, Process: "python3"},
+		{Proto: "udp", Address: "0.0.0.0", Port: 25565},
+		{Proto: "tcp", Address: "127.0.0.1", Port: 3000},
+	}
+	got := mergeListeningPorts(socks, map[string][]string{"tcp/3000": {"路由 web", "临时隧道"}})
+	if len(got) != 3 || got[0].Port != 3000 || got[1].Port != 8080 || got[2].Proto != "udp" {
+		t.Fatalf("merged = %+v", got)
+	}
+	if !reflect.DeepEqual(got[0].UsedBy, []string{"路由 web", "临时隧道"}) || got[1].UsedBy == nil {
+		t.Errorf("used_by = %v / %v", got[0].UsedBy, got[1].UsedBy)
+	}
+	if got[1].Process != "python3" || got[1].PID != 7 {
+		t.Errorf("process not merged: %+v", got[1])
+	}
+}
+
+func TestListListeningSockets(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	socks, err := listListeningSockets()
+	if err != nil {
+		t.Skip(err) // 环境中没有 netstat
+	}
+	for _, s := range socks {
+		if s.Proto == "tcp" && s.Port == port {
+			return
+		}
+	}
+	t.Errorf("listener on %d not found in %d sockets", port, len(socks))
+}
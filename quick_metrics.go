@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== cloudflared 指标采集 ====================
+// 临时隧道启动时为 cloudflared 指定回环 --metrics 地址，定期抓取其 Prometheus 文本，
+// 解析出请求数、状态码分布、边缘连接数与所在机房，供 GetQuickStats 查询。
+// 请求数或 TCP 会话有变化时同时刷新空闲计时，使空闲超时对非 http 源站也生效。
+
+const quickMetricsInterval = 5 * time.Second
+
+type EdgeConnInfo struct {
+	ConnectionID string `json:"connection_id"`
+	Location     string `json:"location"`
+}
+
+type QuickStatsInfo struct {
+	Available          bool             `json:"available"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+	TotalRequests      int64            `json:"total_requests"`
+	RequestErrors      int64            `json:"request_errors"`
+	ConcurrentRequests int64            `json:"concurrent_requests"`
+	ResponsesByCode    map[string]int64 `json:"responses_by_code"`
+	HAConnections      int              `json:"ha_connections"`
+	EdgeConnections    []EdgeConnInfo   `json:"edge_connections"`
+	TCPActiveSessions  int64            `json:"tcp_active_sessions"`
+	TCPTotalSessions   int64            `json:"tcp_total_sessions"`
+	Err                string           `json:"err,omitempty"`
+}
+
+func (a *App) GetQuickStats() QuickStatsInfo {
+	a.quickMu.Lock()
+	defer a.quickMu.Unlock()
+	return a.quickStats
+}
+
+// freeLoopbackAddr: 预先占用再释放一个回环端口，交给 cloudflared 监听指标
+func freeLoopbackAddr() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr, nil
+}
+
+// scrapeQuickMetrics: 定期抓取，直到 done 关闭
+func (a *App) scrapeQuickMetrics(addr string, done <-chan struct{}) {
+	client := &http.Client{Timeout: 3 * time.Second}
+	ticker := time.NewTicker(quickMetricsInterval)
+	defer ticker.Stop()
+
+	var prev QuickStatsInfo
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		stats, err := fetchQuickStats(client, "http://"+addr+"/metrics")
+		if err != nil {
+			a.quickMu.Lock()
+			a.quickStats.Err = err.Error()
+			a.quickMu.Unlock()
+			continue
+		}
+		if stats.TotalRequests != prev.TotalRequests || stats.TCPTotalSessions != prev.TCPTotalSessions ||
+			stats.ConcurrentRequests > 0 || stats.TCPActiveSessions > 0 {
+			a.touchQuick()
+		}
+		prev = stats
+
+		a.quickMu.Lock()
+		a.quickStats = stats
+		a.quickMu.Unlock()
+		a.emit("quick:stats", stats)
+	}
+}
+
+func fetchQuickStats(client *http.Client, url string) (QuickStatsInfo, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return QuickStatsInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return QuickStatsInfo{}, fmt.Errorf("指标接口返回 %s", resp.Status)
+	}
+	samples, err := parsePromText(resp.Body)
+	if err != nil {
+		return QuickStatsInfo{}, err
+	}
+	return quickStatsFromSamples(samples), nil
+}
+
+func quickStatsFromSamples(samples []promSample) QuickStatsInfo {
+	stats := QuickStatsInfo{
+		Available:       true,
+		UpdatedAt:       time.Now(),
+		ResponsesByCode: map[string]int64{},
+	}
+	for _, s := range samples {
+		switch s.Name {
+		case "cloudflared_tunnel_total_requests":
+			stats.TotalRequests += int64(s.Value)
+		case "cloudflared_tunnel_request_errors":
+			stats.RequestErrors += int64(s.Value)
+		case "cloudflared_tunnel_concurrent_requests_per_tunnel":
+			stats.ConcurrentRequests += int64(s.Value)
+		case "cloudflared_tunnel_response_by_code":
+			stats.ResponsesByCode[s.Labels["status_code"]] += int64(s.Value)
+		case "cloudflared_tunnel_ha_connections":
+			stats.HAConnections = int(s.Value)
+		case "cloudflared_tunnel_server_locations":
+			if s.Value > 0 {
+				stats.EdgeConnections = append(stats.EdgeConnections, EdgeConnInfo{
+					ConnectionID: s.Labels["connection_id"],
+					Location:     s.Labels["edge_location"],
+				})
+			}
+		case "cloudflared_tcp_active_sessions":
+			stats.TCPActiveSessions += int64(s.Value)
+		case "cloudflared_tcp_total_sessions":
+			stats.TCPTotalSessions += int64(s.Value)
+		}
+	}
+	sort.Slice(stats.EdgeConnections, func(i, j int) bool {
+		return stats.EdgeConnections[i].ConnectionID < stats.EdgeConnections[j].ConnectionID
+	})
+	return stats
+}
+
+// ---------- Prometheus 文本格式解析 ----------
+
+type promSample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// parsePromText: 解析 Prometheus 文本暴露格式，忽略注释与时间戳
+func parsePromText(r io.Reader) ([]promSample, error) {
+	var samples []promSample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s, err := parsePromLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %v", lineNo, err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, scanner.Err()
+}
+
+func parsePromLine(line string) (promSample, error) {
+	s := promSample{Labels: map[string]string{}}
+	i := strings.IndexAny(line, "{ \t")
+	if i <= 0 {
+		return s, fmt.Errorf("格式错误: %q", line)
+	}
+	s.Name = line[:i]
+	rest := line[i:]
+
+	if rest[0] == '{' {
+		end, err := parsePromLabels(rest, s.Labels)
+		if err != nil {
+			return s, err
+		}
+		rest = rest[end:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return s, fmt.Errorf("缺少数值: %q", line)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("数值无效: %q", fields[0])
+	}
+	s.Value = v
+	return s, nil
+}
+
+// parsePromLabels: 解析 {k="v",...}，返回右花括号之后的位置
+func parsePromLabels(s string, labels map[string]string) (int, error) {
+	i := 1
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return 0, fmt.Errorf("标签未闭合: %q", s)
+		}
+		if s[i] == '}' {
+			return i + 1, nil
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+			return 0, fmt.Errorf("标签格式错误: %q", s)
+		}
+		key := strings.TrimSpace(s[i : i+eq])
+		i += eq + 2
+
+		var val strings.Builder
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					val.WriteByte('\n')
+				default:
+					val.WriteByte(s[i])
+				}
+				continue
+			}
+			val.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return 0, fmt.Errorf("标签值未闭合: %q", s)
+		}
+		labels[key] = val.String()
+		i++
+	}
+}
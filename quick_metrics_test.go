@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleCloudflaredMetrics = `# HELP cloudflared_tunnel_total_requests Amount of requests proxied through all the tunnels
+# TYPE cloudflared_tunnel_total_requests counter
+cloudflared_tunnel_total_requests 42
+cloudflared_tunnel_request_errors 3
+cloudflared_tunnel_concurrent_requests_per_tunnel 1
+cloudflared_tunnel_response_by_code{status_code="200"} 37
+cloudflared_tunnel_response_by_code{status_code="502"} 2
+cloudflared_tunnel_ha_connections 4
+cloudflared_tunnel_server_locations{connection_id="1",edge_location="hkg05"} 1
+cloudflared_tunnel_server_locations{connection_id="0",edge_location="sjc07"} 1
+cloudflared_tunnel_server_locations{connection_id="2",edge_location="lax01"} 0
+go_gc_duration_seconds{quantile="0.5"} 1.2e-05
+process_start_time_seconds 1.7e+09 1712345678000
+`
+
+func TestQuickStatsFromMetrics(t *testing.T) {
+	samples, err := parsePromText(strings.NewReader(sampleCloudflaredMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := quickStatsFromSamples(samples)
+	if s.TotalRequests != 42 || s.RequestErrors != 3 || s.ConcurrentRequests != 1 || s.HAConnections != 4 {
+		t.Errorf("stats = %+v", s)
+	}
+	if s.ResponsesByCode["200"] != 37 || s.ResponsesByCode["502"] != 2 {
+		t.Errorf("ResponsesByCode = %v", s.ResponsesByCode)
+	}
+	if len(s.EdgeConnections) != 2 || s.EdgeConnections[0].Location != "sjc07" {
+		t.Errorf("EdgeConnections = %+v", s.EdgeConnections)
+	}
+}
+
+func TestParsePromLine(t *testing.T) {
+	s, err := parsePromLine(`m{a="x\"y",b="1,2"} 5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "m" || s.Labels["a"] != `x"y` || s.Labels["b"] != "1,2" || s.Value != 5 {
+		t.Errorf("sample = %+v", s)
+	}
+	for _, bad := range []string{`m{a="x"`, `m{a=x} 1`, `m abc`, `{a="b"} 1`} {
+		if _, err := parsePromLine(bad); err == nil {
+			t.Errorf("parsePromLine(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ==================== 临时隧道预设 ====================
+// 常用的源站、访问控制、时限与 webhook 组合保存为命名预设，存放在设置文件中。
+// 同一时间只能运行一条临时隧道，因此最多一个预设可以设置为随应用启动。
+
+type QuickPreset struct {
+	Name          string    `json:"name"`
+	Spec          QuickSpec `json:"spec"`
+	Hooks         []URLHook `json:"hooks,omitempty"` // 仅在该预设运行时触发的地址变更 webhook
+	StartOnLaunch bool      `json:"start_on_launch"`
+}
+
+func (p *QuickPreset) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("预设需要名称")
+	}
+	p.Spec.normalize()
+	if err := p.Spec.Validate(); err != nil {
+		return fmt.Errorf("%s: %v", p.Name, err)
+	}
+	seen := map[string]bool{}
+	for _, h := range p.Hooks {
+		if err := h.Validate(); err != nil {
+			return fmt.Errorf("%s: %v", p.Name, err)
+		}
+		if seen[h.Name] {
+			return fmt.Errorf("%s: webhook 重名: %s", p.Name, h.Name)
+		}
+		seen[h.Name] = true
+	}
+	return nil
+}
+
+func (a *App) GetQuickPresets() []QuickPreset {
+	return nonNil(loadSettings().QuickPresets)
+}
+
+// SaveQuickPreset: 新增或按名称覆盖预设；设置随应用启动时会取消其他预设的该选项
+func (a *App) SaveQuickPreset(p QuickPreset) string {
+	if err := p.Validate(); err != nil {
+		return "错误: " + err.Error()
+	}
+	_, err := updateSettings(func(s *AppSettings) {
+		replaced := false
+		for i := range s.QuickPresets {
+			if p.StartOnLaunch {
+				s.QuickPresets[i].StartOnLaunch = false
+			}
+			if s.QuickPresets[i].Name == p.Name {
+				s.QuickPresets[i] = p
+				replaced = true
+			}
+		}
+		if !replaced {
+			s.QuickPresets = append(s.QuickPresets, p)
+		}
+	})
+	if err != nil {
+		return "错误: " + err.Error()
+	}
+	return "已保存"
+}
+
+func (a *App) DeleteQuickPreset(name string) string {
+	found := false
+	_, err := updateSettings(func(s *AppSettings) {
+		for i, p := range s.QuickPresets {
+			if p.Name == name {
+				s.QuickPresets = append(s.QuickPresets[:i], s.QuickPresets[i+1:]...)
+				found = true
+				return
+			}
+		}
+	})
+	if err != nil {
+		return "错误: " + err.Error()
+	}
+	if !found {
+		return "错误: 未找到预设 " + name
+	}
+	return "已删除"
+}
+
+func (a *App) StartQuickPreset(name string) QuickResult {
+	p, ok := findQuickPreset(loadSettings().QuickPresets, name)
+	if !ok {
+		return QuickResult{Err: "未找到预设 " + name}
+	}
+	return a.startQuickSpec(p.Spec, quickRun{QuickLimits: p.Spec.QuickLimits, Preset: p.Name, Hooks: p.Hooks})
+}
+
+func findQuickPreset(presets []QuickPreset, name string) (QuickPreset, bool) {
+	for _, p := range presets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return QuickPreset{}, false
+}
+
+// startLaunchPreset: 应用启动时运行标记了随应用启动的预设，结果通过 quick:launch 事件推送
+func (a *App) startLaunchPreset(presets []QuickPreset) {
+	for _, p := range presets {
+		if p.StartOnLaunch {
+			res := a.StartQuickPreset(p.Name)
+			a.emit("quick:launch", map[string]interface{}{"preset": p.Name, "result": res})
+			return
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestQuickPresetCRUD(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+
+	for _, p := range []QuickPreset{
+		{Name: "web", Spec: QuickSpec{Port: 3000}, StartOnLaunch: true},
+		{Name: "api", Spec: QuickSpec{Port: 8080, QuickLimits: QuickLimits{TTLSeconds: 3600}}},
+	} {
+		if msg := a.SaveQuickPreset(p); msg != "已保存" {
+			t.Fatal(msg)
+		}
+	}
+	// 覆盖 api 并设为随应用启动，web 的启动标记应被取消
+	if msg := a.SaveQuickPreset(QuickPreset{Name: "api", Spec: QuickSpec{Port: 9090}, StartOnLaunch: true}); msg != "已保存" {
+		t.Fatal(msg)
+	}
+
+	presets := a.GetQuickPresets()
+	if len(presets) != 2 {
+		t.Fatalf("presets = %+v", presets)
+	}
+	web, _ := findQuickPreset(presets, "web")
+	api, _ := findQuickPreset(presets, "api")
+	if web.StartOnLaunch || !api.StartOnLaunch {
+		t.Errorf("start_on_launch: web=%v api=%v", web.StartOnLaunch, api.StartOnLaunch)
+	}
+	if api.Spec.Port != 9090 || api.Spec.Scheme != "http" || api.Spec.Host != "localhost" {
+		t.Errorf("api spec = %+v", api.Spec)
+	}
+
+	if msg := a.DeleteQuickPreset("web"); msg != "已删除" {
+		t.Fatal(msg)
+	}
+	if msg := a.DeleteQuickPreset("web"); msg == "已删除" {
+		t.Error("deleting missing preset succeeded")
+	}
+	if res := a.StartQuickPreset("web"); res.Err == "" {
+		t.Error("starting missing preset succeeded")
+	}
+}
+
+func TestQuickPresetValidate(t *testing.T) {
+	bad := []QuickPreset{
+		{Spec: QuickSpec{Port: 80}},
+		{Name: "x", Spec: QuickSpec{Port: 0}},
+		{Name: "x", Spec: QuickSpec{Port: 80, QuickLimits: QuickLimits{TTLSeconds: -1}}},
+		{Name: "x", Spec: QuickSpec{Port: 80}, Hooks: []URLHook{{Name: "h", URL: "bad"}}},
+		{Name: "x", Spec: QuickSpec{Port: 80}, Hooks: []URLHook{{Name: "h", URL: "https://a"}, {Name: "h", URL: "https://b"}}},
+	}
+	for _, p := range bad {
+		if p.Validate() == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 需要在 cloudflared 与源站之间做处理（访问控制、请求检查等）时，临时隧道改为指向
+// 本机回环端口上的反向代理，源站相关的 Host 头、TLS 选项改由代理负责。
+
+// http 源站设置空闲超时时也走代理，按实际请求计时比指标轮询更及时
+func (s QuickSpec) needsProxy() bool {
+	return s.Gate.enabled() || s.Inspect || (s.IdleSeconds > 0 && s.isHTTP())
+}
+
+// startQuickProxied: 启动前置代理并让 cloudflared 指向它
+func (a *App) startQuickProxied(spec QuickSpec, run quickRun) QuickResult {
+	return a.startQuickFront(newOriginProxy(spec), spec.Gate, spec.Inspect, run)
+}
+
+// wrapQuickHandler: 组装前置处理链；检查器在访问控制之外，被拦截的请求也会被记录，
+// 记录时隐去访问控制自己的凭据
+func (a *App) wrapQuickHandler(h http.Handler, gate *QuickGate, inspect bool) http.Handler {
+	if gate.enabled() {
+		h = gate.wrap(h)
+	}
+	if inspect {
+		h = a.inspect(h, gate)
+	}
+	return a.trackActivity(h)
+}
+
+// startQuickFront: 在回环端口上启动处理链，并让 cloudflared 指向它。
+// 源站处理器会被保留下来供请求重放使用，隧道停止后依然可以重放。
+func (a *App) startQuickFront(origin http.Handler, gate *QuickGate, inspect bool, run quickRun) QuickResult {
+	port, stop, err := startLoopbackServer(a.wrapQuickHandler(origin, gate, inspect))
+	if err != nil {
+		return QuickResult{Err: "启动前置代理失败: " + err.Error()}
+	}
+	front := QuickSpec{Scheme: "http", Host: "127.0.0.1", Port: port}
+	res := a.startQuick(front.cloudflaredArgs(), run, stop)
+	if res.Err == "" {
+		a.quickMu.Lock()
+		a.quickOrigin = origin
+		a.quickGate = gate
+		a.quickMu.Unlock()
+	}
+	return res
+}
+
+func newOriginProxy(spec QuickSpec) *httputil.ReverseProxy {
+	target := &url.URL{Scheme: spec.Scheme, Host: net.JoinHostPort(spec.Host, strconv.Itoa(spec.Port))}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		if spec.HTTPHostHeader != "" {
+			r.Host = spec.HTTPHostHeader
+		} else {
+			r.Host = target.Host
+		}
+	}
+	proxy.Transport = originTransport(spec)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		http.Error(w, "源站不可达: "+err.Error(), http.StatusBadGateway)
+	}
+	return proxy
+}
+
+func originTransport(spec QuickSpec) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil // 源站在本机或局域网，不走系统代理
+	t.ResponseHeaderTimeout = 2 * time.Minute
+	if spec.Scheme == "https" {
+		t.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: spec.NoTLSVerify,
+			ServerName:         spec.OriginServerName,
+		}
+	}
+	return t
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// QuickSpec: 临时隧道的源站描述，不再局限于 http://localhost:PORT
+type QuickSpec struct {
+	Scheme           string `json:"scheme"` // http / https / tcp / ssh / rdp
+	Host             string `json:"host"`
+	Port             int    `json:"port"`
+	NoTLSVerify      bool   `json:"no_tls_verify"`      // 仅 https：跳过源站证书校验
+	HTTPHostHeader   string `json:"http_host_header"`   // 仅 http/https：改写 Host 头
+	OriginServerName string `json:"origin_server_name"` // 仅 https：校验证书时使用的 SNI
+
+	Gate    *QuickGate `json:"gate,omitempty"` // 访问控制，仅 http/https
+	Inspect bool       `json:"inspect"`        // 记录经过隧道的请求，仅 http/https
+	QuickLimits
+}
+
+var quickSchemes = map[string]bool{
+	"http":  true,
+	"https": true,
+	"tcp":   true,
+	"ssh":   true,
+	"rdp":   true,
+}
+
+// normalize: 补全默认值（http、localhost）并统一大小写
+func (s *QuickSpec) normalize() {
+	s.Scheme = strings.ToLower(strings.TrimSpace(s.Scheme))
+	if s.Scheme == "" {
+		s.Scheme = "http"
+	}
+	s.Host = strings.TrimSpace(s.Host)
+	if s.Host == "" {
+		s.Host = "localhost"
+	}
+	s.HTTPHostHeader = strings.TrimSpace(s.HTTPHostHeader)
+	s.OriginServerName = strings.TrimSpace(s.OriginServerName)
+}
+
+func (s QuickSpec) isHTTP() bool {
+	return s.Scheme == "http" || s.Scheme == "https"
+}
+
+func (s QuickSpec) Validate() error {
+	if !quickSchemes[s.Scheme] {
+		return fmt.Errorf("不支持的协议: %q", s.Scheme)
+	}
+	if !validHost(s.Host) {
+		return fmt.Errorf("主机名无效: %q", s.Host)
+	}
+	if s.Port < 1 || s.Port > 65535 {
+		return fmt.Errorf("端口无效: %d", s.Port)
+	}
+	if s.Scheme != "https" {
+		if s.NoTLSVerify {
+			return errors.New("--no-tls-verify 仅适用于 https 源站")
+		}
+		if s.OriginServerName != "" {
+			return errors.New("源站 SNI 仅适用于 https 源站")
+		}
+	}
+	if s.HTTPHostHeader != "" {
+		if !s.isHTTP() {
+			return errors.New("Host 头改写仅适用于 http/https 源站")
+		}
+		if !validHost(s.HTTPHostHeader) && !validHostPort(s.HTTPHostHeader) {
+			return fmt.Errorf("Host 头无效: %q", s.HTTPHostHeader)
+		}
+	}
+	if s.OriginServerName != "" && !validHost(s.OriginServerName) {
+		return fmt.Errorf("源站 SNI 无效: %q", s.OriginServerName)
+	}
+	if s.Gate.enabled() && !s.isHTTP() {
+		return errors.New("访问控制仅适用于 http/https 源站")
+	}
+	if s.Inspect && !s.isHTTP() {
+		return errors.New("请求检查仅适用于 http/https 源站")
+	}
+	if err := s.QuickLimits.Validate(); err != nil {
+		return err
+	}
+	return s.Gate.Validate()
+}
+
+// OriginURL: 交给 cloudflared --url 的源站地址
+func (s QuickSpec) OriginURL() string {
+	return s.Scheme + "://" + net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+func (s QuickSpec) cloudflaredArgs() []string {
+	args := []string{"tunnel", "--url", s.OriginURL()}
+	if s.NoTLSVerify {
+		args = append(args, "--no-tls-verify")
+	}
+	if s.HTTPHostHeader != "" {
+		args = append(args, "--http-host-header", s.HTTPHostHeader)
+	}
+	if s.OriginServerName != "" {
+		args = append(args, "--origin-server-name", s.OriginServerName)
+	}
+	return args
+}
+
+// parseQuickPort: 校验前端传入的端口字符串
+func parseQuickPort(port string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(port))
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("端口无效: %q", port)
+	}
+	return p, nil
+}
+
+// validHostPort: 主机名或 IP 加端口，如 1.2.3.4:7000、[::1]:8080
+func validHostPort(h string) bool {
+	host, port, err := net.SplitHostPort(h)
+	if err != nil {
+		return false
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return false
+	}
+	return validHost(host)
+}
+
+// validHost: 主机名或 IP，不带端口
+func validHost(h string) bool {
+	if h == "" || len(h) > 253 {
+		return false
+	}
+	if net.ParseIP(h) != nil {
+		return true
+	}
+	for _, label := range strings.Split(h, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+			default:
+				return false
+			}
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+	}
+	return true
+}
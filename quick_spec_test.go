@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuickSpecValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		spec QuickSpec
+		ok   bool
+	}{
+		{"默认 http", QuickSpec{Port: 8080}, true},
+		{"https 跳过校验", QuickSpec{Scheme: "HTTPS", Host: "192.168.1.20", Port: 8443, NoTLSVerify: true, OriginServerName: "dev.lan"}, true},
+		{"ssh 局域网主机", QuickSpec{Scheme: "ssh", Host: "nas.lan", Port: 22}, true},
+		{"IPv6", QuickSpec{Scheme: "rdp", Host: "::1", Port: 3389}, true},
+		{"未知协议", QuickSpec{Scheme: "ftp", Port: 21}, false},
+		{"端口越界", QuickSpec{Port: 70000}, false},
+		{"端口为 0", QuickSpec{}, false},
+		{"主机含路径", QuickSpec{Host: "a.com/x", Port: 80}, false},
+		{"主机含端口", QuickSpec{Host: "localhost:8080", Port: 3000}, false},
+		{"SNI 含端口", QuickSpec{Scheme: "https", Port: 443, OriginServerName: "dev.lan:443"}, false},
+		{"Host 头可带端口", QuickSpec{Port: 80, HTTPHostHeader: "app.local:8080"}, true},
+		{"http 不能跳过 TLS 校验", QuickSpec{Port: 80, NoTLSVerify: true}, false},
+		{"tcp 不能改 Host 头", QuickSpec{Scheme: "tcp", Port: 5432, HTTPHostHeader: "db"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			spec.normalize()
+			if err := spec.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() err = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestQuickSpecArgs(t *testing.T) {
+	spec := QuickSpec{Scheme: "https", Host: "10.0.0.5", Port: 443, NoTLSVerify: true, HTTPHostHeader: "app.local"}
+	want := []string{"tunnel", "--url", "https://10.0.0.5:443", "--no-tls-verify", "--http-host-header", "app.local"}
+	if got := spec.cloudflaredArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("cloudflaredArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestParseQuickPort(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+		ok    bool
+	}{
+		{"8080", 8080, true},
+		{" 3000 ", 3000, true},
+		{"0", 0, false},
+		{"65536", 0, false},
+		{"80; rm -rf", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseQuickPort(tt.input)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseQuickPort(%q) = %d, %v", tt.input, got, err)
+		}
+	}
+}
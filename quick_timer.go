@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ==================== 临时隧道定时停止 ====================
+// TTL：启动后固定时长自动停止；空闲超时：一段时间没有流量后自动停止
+// （http 源站按前置代理的请求计时，其他源站按 cloudflared 指标计时）。
+// 到期后停止隧道并推送 quick:expired 事件（"ttl" 或 "idle"）。
+
+const maxQuickLimit = 7 * 24 * 3600
+
+type QuickLimits struct {
+	TTLSeconds  int `json:"ttl_seconds"`
+	IdleSeconds int `json:"idle_seconds"`
+}
+
+func (l QuickLimits) Validate() error {
+	if l.TTLSeconds < 0 || l.IdleSeconds < 0 {
+		return errors.New("时长不能为负数")
+	}
+	if l.TTLSeconds > maxQuickLimit || l.IdleSeconds > maxQuickLimit {
+		return errors.New("时长不能超过 7 天")
+	}
+	return nil
+}
+
+type QuickStatusInfo struct {
+	Running              bool   `json:"running"`
+	URL                  string `json:"url"`
+	TTLSeconds           int    `json:"ttl_seconds"`
+	IdleSeconds          int    `json:"idle_seconds"`
+	RemainingSeconds     int64  `json:"remaining_seconds"`      // 距 TTL 到期，未设置为 -1
+	IdleRemainingSeconds int64  `json:"idle_remaining_seconds"` // 距空闲停止，未设置为 -1
+	Preset               string `json:"preset,omitempty"`
+}
+
+// QuickStatus: 运行状态与剩余时间；QuickRunning 保持返回 bool 以兼容现有前端
+func (a *App) QuickStatus() QuickStatusInfo {
+	info := QuickStatusInfo{
+		Running:              a.QuickRunning(),
+		URL:                  a.QuickURL(),
+		RemainingSeconds:     -1,
+		IdleRemainingSeconds: -1,
+	}
+	a.quickMu.Lock()
+	owned := a.quickCmd != nil
+	limits, started, preset := a.quickLimits, a.quickStarted, a.quickPreset
+	a.quickMu.Unlock()
+	if !owned {
+		return info
+	}
+	info.Preset = preset
+
+	info.TTLSeconds, info.IdleSeconds = limits.TTLSeconds, limits.IdleSeconds
+	ttlLeft, idleLeft := a.quickRemaining(limits, started, time.Now())
+	if limits.TTLSeconds > 0 {
+		info.RemainingSeconds = int64(ttlLeft.Seconds())
+	}
+	if limits.IdleSeconds > 0 {
+		info.IdleRemainingSeconds = int64(idleLeft.Seconds())
+	}
+	return info
+}
+
+func (a *App) quickRemaining(limits QuickLimits, started, now time.Time) (ttl, idle time.Duration) {
+	ttl = started.Add(time.Duration(limits.TTLSeconds) * time.Second).Sub(now)
+	last := time.Unix(0, a.quickActive.Load())
+	idle = last.Add(time.Duration(limits.IdleSeconds) * time.Second).Sub(now)
+	return max(ttl, 0), max(idle, 0)
+}
+
+// quickExpired: 返回到期原因，未到期返回空串
+func (a *App) quickExpired(limits QuickLimits, started, now time.Time) string {
+	ttl, idle := a.quickRemaining(limits, started, now)
+	if limits.TTLSeconds > 0 && ttl <= 0 {
+		return "ttl"
+	}
+	if limits.IdleSeconds > 0 && idle <= 0 {
+		return "idle"
+	}
+	return ""
+}
+
+func (a *App) touchQuick() {
+	a.quickActive.Store(time.Now().UnixNano())
+}
+
+func (a *App) trackActivity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.touchQuick()
+		next.ServeHTTP(w, r)
+		a.touchQuick() // 长请求结束时也算活跃
+	})
+}
+
+// watchQuick: 每秒检查一次是否到期；done 在 cloudflared 退出时关闭
+func (a *App) watchQuick(limits QuickLimits, done <-chan struct{}) {
+	if limits.TTLSeconds == 0 && limits.IdleSeconds == 0 {
+		return
+	}
+	a.quickMu.Lock()
+	started := a.quickStarted
+	a.quickMu.Unlock()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if reason := a.quickExpired(limits, started, now); reason != "" {
+				a.QuickStop()
+				a.emit("quick:expired", reason)
+				return
+			}
+		}
+	}
+}
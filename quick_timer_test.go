@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuickExpired(t *testing.T) {
+	a := NewApp()
+	start := time.Unix(1000, 0)
+	a.quickActive.Store(start.Add(30 * time.Second).UnixNano())
+
+	tests := []struct {
+		name   string
+		limits QuickLimits
+		now    time.Time
+		want   string
+	}{
+		{"未设置", QuickLimits{}, start.Add(time.Hour), ""},
+		{"TTL 未到", QuickLimits{TTLSeconds: 60}, start.Add(59 * time.Second), ""},
+		{"TTL 到期", QuickLimits{TTLSeconds: 60}, start.Add(60 * time.Second), "ttl"},
+		{"空闲未到", QuickLimits{IdleSeconds: 60}, start.Add(89 * time.Second), ""},
+		{"空闲到期", QuickLimits{IdleSeconds: 60}, start.Add(90 * time.Second), "idle"},
+		{"TTL 优先", QuickLimits{TTLSeconds: 10, IdleSeconds: 10}, start.Add(time.Hour), "ttl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.quickExpired(tt.limits, start, tt.now); got != tt.want {
+				t.Errorf("quickExpired() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuickLimitsValidate(t *testing.T) {
+	for _, l := range []QuickLimits{{TTLSeconds: -1}, {IdleSeconds: maxQuickLimit + 1}} {
+		if l.Validate() == nil {
+			t.Errorf("Validate(%+v) = nil, want error", l)
+		}
+	}
+}
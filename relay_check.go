@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// relayCheckState: 最近一次中继检查结果与累计次数，供指标导出使用
+type relayCheckState struct {
+	last     CheckResultInfo
+	lastErr  string
+	lastAt   time.Time
+	total    int64
+	failures int64
+}
+
+func runRelayCheck() (CheckResultInfo, error) {
+	return parseRelayCheck(runCftunnel("relay", "check", "--json"))
+}
+
+// parseRelayCheck: cftunnel 在有规则不通时以非零状态退出，但仍输出完整 JSON；
+// 能解析时以 JSON 为准（失败体现在 ServerOK/Failed 中），只有没有结果时才返回错误
+func parseRelayCheck(out string, err error) (CheckResultInfo, error) {
+	var result CheckResultInfo
+	jerr := json.Unmarshal([]byte(out), &result)
+	if jerr == nil {
+		return result, nil
+	}
+	if err == nil {
+		err = jerr
+	}
+	return result, err
+}
+
+func checkFailed(res CheckResultInfo, err error) bool {
+	return err != nil || !res.ServerOK || res.Failed > 0
+}
+
+// recordRelayCheck: 更新最近结果与计数，追加到历史文件并检查是否需要告警
+func (a *App) recordRelayCheck(res CheckResultInfo, err error) {
+	now := time.Now()
+	a.relayMu.Lock()
+	st := &a.relayCheck
+	st.last = res
+	st.lastAt = now
+	st.lastErr = ""
+	if err != nil {
+		st.lastErr = err.Error()
+	}
+	st.total++
+	if checkFailed(res, err) {
+		st.failures++
+	}
+	a.relayMu.Unlock()
+
+	_ = appendRelayHistory(relayCheckPoint(now, res, err))
+	a.observeRelayCheck(res, err, now)
+}
+
+// ==================== 定时中继检查 ====================
+
+const minRelayCheckInterval = 30
+
+type RelayCheckScheduleInfo struct {
+	IntervalSeconds int    `json:"interval_seconds"` // 0 表示关闭
+	RetentionDays   int    `json:"retention_days"`
+	Running         bool   `json:"running"`
+	Err             string `json:"err,omitempty"`
+}
+
+func (a *App) GetRelayCheckSchedule() RelayCheckScheduleInfo {
+	s := loadSettings().RelayCheck
+	a.relayMu.Lock()
+	running := a.relaySchedStop != nil
+	a.relayMu.Unlock()
+	return RelayCheckScheduleInfo{IntervalSeconds: s.IntervalSeconds, RetentionDays: s.retentionDays(), Running: running}
+}
+
+// SetRelayCheckSchedule: 设置后台检查间隔（秒，0 关闭）与历史保留天数（0 使用默认值）
+func (a *App) SetRelayCheckSchedule(intervalSeconds, retentionDays int) RelayCheckScheduleInfo {
+	if intervalSeconds != 0 && intervalSeconds < minRelayCheckInterval {
+		return RelayCheckScheduleInfo{Err: fmt.Sprintf("检查间隔不能小于 %d 秒", minRelayCheckInterval)}
+	}
+	if retentionDays < 0 {
+		return RelayCheckScheduleInfo{Err: "保留天数无效"}
+	}
+	s, err := updateSettings(func(s *AppSettings) {
+		s.RelayCheck.IntervalSeconds = intervalSeconds
+		s.RelayCheck.RetentionDays = retentionDays
+	})
+	if err != nil {
+		return RelayCheckScheduleInfo{Err: "保存设置失败: " + err.Error()}
+	}
+	a.stopRelayScheduler()
+	if intervalSeconds > 0 {
+		a.startRelayScheduler(s.RelayCheck)
+	}
+	return a.GetRelayCheckSchedule()
+}
+
+func (a *App) startRelayScheduler(cfg RelayCheckSettings) {
+	stop := make(chan struct{})
+	a.relayMu.Lock()
+	a.relaySchedStop = stop
+	a.relayMu.Unlock()
+	go a.runRelayScheduler(cfg, stop)
+}
+
+func (a *App) stopRelayScheduler() {
+	a.relayMu.Lock()
+	stop := a.relaySchedStop
+	a.relaySchedStop = nil
+	a.relayMu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+}
+
+func (a *App) runRelayScheduler(cfg RelayCheckSettings, stop <-chan struct{}) {
+	retention := time.Duration(cfg.retentionDays()) * 24 * time.Hour
+	_ = pruneRelayHistory(time.Now().Add(-retention))
+	lastPrune := time.Now()
+
+	ticker := time.NewTicker(time.Duration(cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		res, err := runRelayCheck()
+		a.recordRelayCheck(res, err)
+		a.emit("relay:check", res)
+
+		if time.Since(lastPrune) > 24*time.Hour {
+			_ = pruneRelayHistory(time.Now().Add(-retention))
+			lastPrune = time.Now()
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ==================== 中继检查历史 ====================
+// 每次检查追加一行 JSON 到状态目录的 relay-history.jsonl，按保留天数定期清理，
+// GetRelayCheckHistory 按规则与时间范围取出数据点并计算可用率。
+
+var relayHistoryMu sync.Mutex
+
+// RelayCheckPoint: 历史文件中的一行
+type RelayCheckPoint struct {
+	Time          time.Time       `json:"time"`
+	Server        string          `json:"server"`
+	ServerOK      bool            `json:"server_ok"`
+	ServerLatency int64           `json:"server_latency_ms"`
+	Rules         []RuleCheckInfo `json:"rules"`
+	Err           string          `json:"err,omitempty"`
+}
+
+// RelayHistoryPoint: 单个对象（服务器或某条规则）在某次检查中的状态
+type RelayHistoryPoint struct {
+	Time      time.Time `json:"time"`
+	OK        bool      `json:"ok"`
+	LocalOK   bool      `json:"local_ok"`
+	RemoteOK  bool      `json:"remote_ok"`
+	LatencyMS int64     `json:"latency_ms"`
+	Err       string    `json:"err,omitempty"`
+}
+
+type RelayCheckHistory struct {
+	Rule   string              `json:"rule"` // 为空表示中继服务器本身
+	Points []RelayHistoryPoint `json:"points"`
+	Checks int                 `json:"checks"`
+	Up     int                 `json:"up"`
+	Uptime float64             `json:"uptime"` // 0~1，无数据时为 0
+	Err    string              `json:"err,omitempty"`
+}
+
+func relayHistoryPath() string {
+	return filepath.Join(stateDir(), "relay-history.jsonl")
+}
+
+func relayCheckPoint(t time.Time, res CheckResultInfo, err error) RelayCheckPoint {
+	p := RelayCheckPoint{
+		Time:          t,
+		Server:        res.Server,
+		ServerOK:      res.ServerOK,
+		ServerLatency: res.ServerLatency,
+		Rules:         res.Rules,
+	}
+	if err != nil {
+		p.Err = err.Error()
+	}
+	return p
+}
+
+func appendRelayHistory(p RelayCheckPoint) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	relayHistoryMu.Lock()
+	defer relayHistoryMu.Unlock()
+	_ = os.MkdirAll(stateDir(), 0700)
+	f, err := os.OpenFile(relayHistoryPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readRelayHistory: 读取 since 之后的记录，损坏的行直接跳过
+func readRelayHistory(since time.Time) ([]RelayCheckPoint, error) {
+	relayHistoryMu.Lock()
+	defer relayHistoryMu.Unlock()
+	return readRelayHistoryLocked(since)
+}
+
+func readRelayHistoryLocked(since time.Time) ([]RelayCheckPoint, error) {
+	f, err := os.Open(relayHistoryPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var points []RelayCheckPoint
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	for scanner.Scan() {
+		var p RelayCheckPoint
+		if json.Unmarshal(scanner.Bytes(), &p) != nil || p.Time.Before(since) {
+			continue
+		}
+		points = append(points, p)
+	}
+	return points, scanner.Err()
+}
+
+// pruneRelayHistory: 删除 cutoff 之前的记录；读取与重写之间持有锁，避免丢失并发追加的记录
+func pruneRelayHistory(cutoff time.Time) error {
+	relayHistoryMu.Lock()
+	defer relayHistoryMu.Unlock()
+	points, err := readRelayHistoryLocked(cutoff)
+	if err != nil {
+		return err
+	}
+	tmp := relayHistoryPath() + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, p := range points {
+		if err := enc.Encode(p); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, relayHistoryPath())
+}
+
+// GetRelayCheckHistory: rule 为空时返回服务器可达性；rangeSeconds 为 0 时返回全部历史
+func (a *App) GetRelayCheckHistory(rule string, rangeSeconds int64) RelayCheckHistory {
+	var since time.Time
+	if rangeSeconds > 0 {
+		since = time.Now().Add(-time.Duration(rangeSeconds) * time.Second)
+	}
+	points, err := readRelayHistory(since)
+	h := summarizeRelayHistory(rule, points)
+	if err != nil {
+		h.Err = err.Error()
+	}
+	return h
+}
+
+func summarizeRelayHistory(rule string, points []RelayCheckPoint) RelayCheckHistory {
+	h := RelayCheckHistory{Rule: rule, Points: []RelayHistoryPoint{}}
+	for _, p := range points {
+		hp := RelayHistoryPoint{Time: p.Time, Err: p.Err}
+		if rule == "" {
+			hp.OK = p.ServerOK
+			hp.LatencyMS = p.ServerLatency
+		} else {
+			found := false
+			for _, r := range p.Rules {
+				if r.Name == rule {
+					found = true
+					hp.LocalOK, hp.RemoteOK = r.LocalOK, r.RemoteOK
+					hp.OK = r.RemoteOK // 对外可用以远程端口为准
+					hp.LatencyMS = r.LatencyMS
+					if hp.Err == "" {
+						hp.Err = r.RemoteErr
+					}
+					break
+				}
+			}
+			// 检查本身失败时规则列表为空，计为不可用；规则尚不存在时跳过
+			if !found && p.Err == "" {
+				continue
+			}
+		}
+		h.Points = append(h.Points, hp)
+		h.Checks++
+		if hp.OK {
+			h.Up++
+		}
+	}
+	if h.Checks > 0 {
+		h.Uptime = float64(h.Up) / float64(h.Checks)
+	}
+	return h
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRelayHistoryRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	ok := CheckResultInfo{ServerOK: true, ServerLatency: 20, Rules: []RuleCheckInfo{{Name: "mc", LocalOK: true, RemoteOK: true, LatencyMS: 25}}}
+	down := CheckResultInfo{ServerOK: true, ServerLatency: 22, Rules: []RuleCheckInfo{{Name: "mc", LocalOK: false, RemoteOK: false, RemoteErr: "timeout"}}}
+
+	a.recordRelayCheck(ok, nil)
+	a.recordRelayCheck(down, nil)
+	a.recordRelayCheck(CheckResultInfo{}, errors.New("exit status 1"))
+	a.recordRelayCheck(ok, nil)
+
+	h := a.GetRelayCheckHistory("mc", 3600)
+	if h.Err != "" || h.Checks != 4 || h.Up != 2 || h.Uptime != 0.5 {
+		t.Errorf("rule history = %+v", h)
+	}
+	if h.Points[1].Err != "timeout" {
+		t.Errorf("point err = %q, want remote err", h.Points[1].Err)
+	}
+
+	server := a.GetRelayCheckHistory("", 0)
+	if server.Checks != 4 || server.Up != 3 {
+		t.Errorf("server history = %+v", server)
+	}
+	if other := a.GetRelayCheckHistory("ssh", 0); other.Checks != 1 {
+		t.Errorf("unknown rule should only count failed checks, got %+v", other)
+	}
+}
+
+func TestPruneRelayHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	old := time.Now().Add(-48 * time.Hour)
+	_ = appendRelayHistory(RelayCheckPoint{Time: old, ServerOK: true})
+	_ = appendRelayHistory(RelayCheckPoint{Time: time.Now(), ServerOK: true})
+
+	if err := pruneRelayHistory(time.Now().Add(-24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	points, err := readRelayHistory(time.Time{})
+	if err != nil || len(points) != 1 {
+		t.Errorf("after prune: %d points, err %v", len(points), err)
+	}
+}
+
+func TestPruneRelayHistoryConcurrentAppend(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cutoff := time.Now().Add(-time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = appendRelayHistory(RelayCheckPoint{Time: time.Now(), ServerOK: true})
+		}()
+		go func() {
+			defer wg.Done()
+			_ = pruneRelayHistory(cutoff)
+		}()
+	}
+	wg.Wait()
+	points, err := readRelayHistory(time.Time{})
+	if err != nil || len(points) != 50 {
+		t.Errorf("after concurrent prune: %d points, err %v", len(points), err)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ==================== 中继配置 ====================
+// 为不同的中继服务器（VPS）保存命名配置：服务器地址、令牌与规则集。切换时先停止中继，
+// 用新配置重新 relay init，再把中继规则同步为该配置的规则集，原先运行中则重新启动；
+// 中途失败按相反顺序撤销。切换成功后把切换前的实际规则写回原配置，切回来时原样恢复。
+// 尚未使用过配置时，按当前服务器地址找到对应的已保存配置；找不到则拒绝切换，
+// 否则切换前的规则无处保存，失败时也无法恢复原服务器。
+// 令牌按服务器保存在凭据库中；早期版本写在设置文件里的令牌仍可使用，重新保存时迁入凭据库。
+
+type RelayProfile struct {
+	Name   string          `json:"name"`
+	Server string          `json:"server"`
+	Token  string          `json:"token,omitempty"` // 仅用于提交与兼容旧设置，保存时写入凭据库
+	Rules  []RelayRuleInfo `json:"rules"`
+}
+
+// RelayProfileInfo: 返回给前端的配置，不含令牌
+type RelayProfileInfo struct {
+	Name     string          `json:"name"`
+	Server   string          `json:"server"`
+	HasToken bool            `json:"has_token"`
+	Rules    []RelayRuleInfo `json:"rules"`
+	Active   bool            `json:"active"`
+}
+
+type RelaySwitchResult struct {
+	Profile string            `json:"profile"`
+	Rules   []RelayRuleChange `json:"rules"`
+	StepsOutcome
+	Err string `json:"err,omitempty"`
+}
+
+func (p *RelayProfile) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Server = strings.TrimSpace(p.Server)
+	if p.Name == "" {
+		return errors.New("配置需要名称")
+	}
+	if !validHostPort(p.Server) {
+		return fmt.Errorf("%s: 服务器地址无效: %q", p.Name, p.Server)
+	}
+	p.Rules = nonNil(p.Rules)
+	if errs := validateRelayRules(p.Rules); len(errs) > 0 {
+		return fmt.Errorf("%s: %s", p.Name, strings.Join(errs, "；"))
+	}
+	return nil
+}
+
+func (a *App) GetRelayProfiles() []RelayProfileInfo {
+	s := loadSettings()
+	list := []RelayProfileInfo{}
+	for _, p := range s.RelayProfiles {
+		list = append(list, RelayProfileInfo{
+			Name:     p.Name,
+			Server:   p.Server,
+			HasToken: p.Token != "" || a.vault.has(relayTokenKey(p.Server)),
+			Rules:    nonNil(p.Rules),
+			Active:   p.Name == s.ActiveRelayProfile,
+		})
+	}
+	return list
+}
+
+// SaveRelayProfile: 新增或按名称覆盖；令牌写入凭据库，留空时保留原有令牌
+func (a *App) SaveRelayProfile(p RelayProfile) string {
+	if err := p.Validate(); err != nil {
+		return "错误: " + err.Error()
+	}
+	if p.Token == "" {
+		// 沿用原有令牌：旧设置中的令牌迁入凭据库，改了服务器地址时复制到新地址
+		if old, ok := findRelayProfile(loadSettings().RelayProfiles, p.Name); ok && (old.Token != "" || old.Server != p.Server) {
+			p.Token, _ = a.relayProfileToken(old)
+		}
+	}
+	if p.Token != "" {
+		if err := a.vault.set(relayTokenKey(p.Server), p.Token); err != nil {
+			return "错误: 保存令牌失败: " + err.Error()
+		}
+		p.Token = ""
+	}
+	hasToken := a.vault.has(relayTokenKey(p.Server))
+	var saveErr error
+	_, err := updateSettings(func(s *AppSettings) {
+		for i := range s.RelayProfiles {
+			if s.RelayProfiles[i].Name == p.Name {
+				s.RelayProfiles[i] = p
+				return
+			}
+		}
+		if !hasToken {
+			saveErr = errors.New("新配置需要令牌")
+			return
+		}
+		s.RelayProfiles = append(s.RelayProfiles, p)
+	})
+	if saveErr != nil {
+		err = saveErr
+	}
+	if err != nil {
+		return "错误: " + err.Error()
+	}
+	return "已保存"
+}
+
+// CloneRelayProfile: 复制配置；复制当前使用的配置时取实际运行的规则
+func (a *App) CloneRelayProfile(src, dst string) string {
+	dst = strings.TrimSpace(dst)
+	s := loadSettings()
+	p, ok := findRelayProfile(s.RelayProfiles, src)
+	if !ok {
+		return "错误: 未找到中继配置 " + src
+	}
+	if _, exists := findRelayProfile(s.RelayProfiles, dst); exists {
+		return "错误: 中继配置已存在: " + dst
+	}
+	if src == s.ActiveRelayProfile {
+		if rules, err := (currentStateReader{}).relayRules(); err == nil {
+			p.Rules = rules
+		}
+	}
+	p.Name = dst
+	p.Rules = append([]RelayRuleInfo{}, p.Rules...)
+	return a.SaveRelayProfile(p)
+}
+
+func (a *App) DeleteRelayProfile(name string) string {
+	var delErr error
+	_, err := updateSettings(func(s *AppSettings) {
+		if name != "" && name == s.ActiveRelayProfile {
+			delErr = errors.New("不能删除当前使用的中继配置")
+			return
+		}
+		for i, p := range s.RelayProfiles {
+			if p.Name == name {
+				s.RelayProfiles = append(s.RelayProfiles[:i], s.RelayProfiles[i+1:]...)
+				return
+			}
+		}
+		delErr = errors.New("未找到中继配置 " + name)
+	})
+	if delErr != nil {
+		err = delErr
+	}
+	if err != nil {
+		return "错误: " + err.Error()
+	}
+	return "已删除"
+}
+
+func (a *App) SwitchRelayProfile(name string) RelaySwitchResult {
+	return switchRelayProfile(name, currentStateReader{}, runCftunnelSecret, a.relayProfileToken)
+}
+
+// relayProfileToken: 优先使用旧设置中的令牌，否则从凭据库读取
+func (a *App) relayProfileToken(p RelayProfile) (string, error) {
+	if p.Token != "" {
+		return p.Token, nil
+	}
+	token, err := a.vault.get(relayTokenKey(p.Server))
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", fmt.Errorf("凭据库中没有中继服务器 %s 的令牌", p.Server)
+	}
+	return token, nil
+}
+
+func switchRelayProfile(name string, state stateReader, run cftunnelRunner, token func(RelayProfile) (string, error)) RelaySwitchResult {
+	res := RelaySwitchResult{Profile: name, Rules: []RelayRuleChange{}}
+	s := loadSettings()
+	p, ok := findRelayProfile(s.RelayProfiles, name)
+	if !ok {
+		res.Err = "未找到中继配置 " + name
+		return res
+	}
+	out, _ := run("relay", "status")
+	status := parseRelayStatus(out)
+	prev, ok := findRelayProfile(s.RelayProfiles, s.ActiveRelayProfile)
+	if s.ActiveRelayProfile == "" {
+		prev, ok = findRelayProfileByServer(s.RelayProfiles, status.Server)
+	}
+	if !ok {
+		res.Err = "当前中继服务器 " + status.Server + " 还没有保存为配置，请先保存当前服务器再切换"
+		return res
+	}
+	prevToken, err := token(prev)
+	if err != nil {
+		res.Err = "读取当前配置 " + prev.Name + " 的令牌失败，切换失败时无法恢复: " + err.Error()
+		return res
+	}
+	newToken, err := token(p)
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	current, err := state.relayRules()
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	running := status.Running
+
+	initStep := cftunnelStep{
+		Desc: "切换中继服务器 " + p.Server,
+		Args: relayInitArgs(p.Server, newToken),
+		Undo: relayInitArgs(prev.Server, prevToken),
+	}
+	res.Rules = diffRelayRules(current, p.Rules)
+
+	var steps []cftunnelStep
+	if running {
+		steps = append(steps, cftunnelStep{Desc: "停止中继", Args: []string{"relay", "down"}, Undo: []string{"relay", "up"}})
+	}
+	steps = append(steps, initStep)
+	steps = append(steps, relaySteps(res.Rules)...)
+	if running {
+		steps = append(steps, cftunnelStep{Desc: "启动中继", Args: []string{"relay", "up"}, Undo: []string{"relay", "down"}})
+	}
+	if res.StepsOutcome, err = applySteps(run, steps); err != nil {
+		res.Err = err.Error()
+		if res.RolledBack && len(res.RollbackErrors) == 0 {
+			res.Err += "（已回滚）"
+		}
+		return res
+	}
+
+	if _, err := updateSettings(func(s *AppSettings) {
+		for i := range s.RelayProfiles {
+			if s.RelayProfiles[i].Name == prev.Name && prev.Name != name {
+				s.RelayProfiles[i].Rules = nonNil(current)
+			}
+		}
+		s.ActiveRelayProfile = name
+	}); err != nil {
+		res.Err = "已切换，但保存设置失败: " + err.Error()
+	}
+	return res
+}
+
+func findRelayProfile(profiles []RelayProfile, name string) (RelayProfile, bool) {
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return RelayProfile{}, false
+}
+
+func findRelayProfileByServer(profiles []RelayProfile, server string) (RelayProfile, bool) {
+	for _, p := range profiles {
+		if server != "" && p.Server == server {
+			return p, true
+		}
+	}
+	return RelayProfile{}, false
+}
+
+func relayInitArgs(server, token string) []string {
+	return []string{"relay", "init", "--server", server, "--token", token}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRelayProfiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	if msg := a.SaveRelayProfile(RelayProfile{Name: "acme", Server: "1.2.3.4:7000", Token: "t1"}); !strings.Contains(msg, "未解锁") {
+		t.Errorf("saved token into locked vault: %s", msg)
+	}
+	a.UnlockVault("correct horse")
+	if msg := a.SaveRelayProfile(RelayProfile{Name: "acme", Server: "1.2.3.4:7000"}); !strings.HasPrefix(msg, "错误") {
+		t.Error("profile without token accepted")
+	}
+	if msg := a.SaveRelayProfile(RelayProfile{Name: "acme", Server: "1.2.3.4", Token: "t1"}); !strings.HasPrefix(msg, "错误") {
+		t.Error("server without port accepted")
+	}
+	if msg := a.SaveRelayProfile(RelayProfile{Name: "acme", Server: "1.2.3.4:7000", Token: "t1", Rules: []RelayRuleInfo{
+		{Name: "a", Proto: "tcp", LocalPort: 22, RemotePort: 2222},
+		{Name: "b", Proto: "tcp", LocalPort: 23, RemotePort: 2222},
+	}}); !strings.HasPrefix(msg, "错误") {
+		t.Error("conflicting rules accepted")
+	}
+	if msg := a.SaveRelayProfile(RelayProfile{Name: "acme", Server: "1.2.3.4:7000", Token: "t1", Rules: []RelayRuleInfo{
+		{Name: "ssh", Proto: "tcp", LocalPort: 22, RemotePort: 2222},
+	}}); msg != "已保存" {
+		t.Fatal(msg)
+	}
+	// 令牌留空时保留原令牌
+	if msg := a.SaveRelayProfile(RelayProfile{Name: "acme", Server: "1.2.3.4:7001", Rules: []RelayRuleInfo{
+		{Name: "ssh", Proto: "tcp", LocalPort: 22, RemotePort: 2222},
+	}}); msg != "已保存" {
+		t.Fatal(msg)
+	}
+	if msg := a.CloneRelayProfile("acme", "globex"); msg != "已保存" {
+		t.Fatal(msg)
+	}
+	list := a.GetRelayProfiles()
+	if data, _ := os.ReadFile(settingsPath()); strings.Contains(string(data), "t1") {
+		t.Error("token written to settings")
+	}
+	if len(list) != 2 || !list[1].HasToken || list[1].Server != "1.2.3.4:7001" || len(list[1].Rules) != 1 {
+		t.Errorf("profiles = %+v", list)
+	}
+	if msg := a.CloneRelayProfile("acme", "globex"); !strings.HasPrefix(msg, "错误") {
+		t.Error("clone over existing profile accepted")
+	}
+	if msg := a.DeleteRelayProfile("globex"); msg != "已删除" {
+		t.Error(msg)
+	}
+}
+
+func TestSwitchRelayProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	a.UnlockVault("correct horse")
+	a.SaveRelayProfile(RelayProfile{Name: "acme", Server: "1.2.3.4:7000", Token: "t1"})
+	a.SaveRelayProfile(RelayProfile{Name: "globex", Server: "5.6.7.8:7000", Token: "t2", Rules: []RelayRuleInfo{
+		{Name: "web", Proto: "tcp", LocalPort: 8080, RemotePort: 8080},
+	}})
+
+	// 当前服务器没有保存为配置时拒绝切换，避免丢失当前规则
+	f := newFakeRelay()
+	f.server = "9.9.9.9:7000"
+	if res := switchRelayProfile("globex", f, f.run, a.relayProfileToken); res.Err == "" || len(f.calls) != 1 {
+		t.Errorf("switch without active profile: %+v %q", res, f.calls)
+	}
+
+	// 尚未使用配置时按服务器地址认出当前配置 acme
+	f.server = "1.2.3.4:7000"
+	f.calls = nil
+	res := switchRelayProfile("globex", f, f.run, a.relayProfileToken)
+	if res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	want := []string{
+		"relay status",
+		"relay init --server 5.6.7.8:7000 --token t2",
+		"relay remove mc",
+		"relay remove ssh",
+		"relay add web --proto tcp --local 8080 --remote 8080",
+	}
+	if !reflect.DeepEqual(f.calls, want) {
+		t.Errorf("calls = %q", f.calls)
+	}
+	s := loadSettings()
+	if s.ActiveRelayProfile != "globex" || len(s.RelayProfiles[0].Rules) != 2 {
+		t.Errorf("settings = %+v", s)
+	}
+	if msg := a.DeleteRelayProfile("globex"); !strings.HasPrefix(msg, "错误") {
+		t.Error("deleted active profile")
+	}
+
+	// 凭据库锁定时无法取得令牌，不执行任何修改
+	a.LockVault()
+	f.calls = nil
+	if res := switchRelayProfile("acme", f, f.run, a.relayProfileToken); res.Err == "" || len(f.calls) != 1 {
+		t.Errorf("locked vault: %+v %q", res, f.calls)
+	}
+	a.UnlockVault("correct horse")
+
+	// 切回 acme 时恢复之前的规则；添加失败则回滚到 globex
+	f.calls = nil
+	f.fail = "relay add ssh"
+	res = switchRelayProfile("acme", f, f.run, a.relayProfileToken)
+	if !res.RolledBack || !strings.Contains(res.Err, "已回滚") {
+		t.Errorf("res = %+v", res)
+	}
+	if last := f.calls[len(f.calls)-1]; last != "relay init --server 5.6.7.8:7000 --token t2" {
+		t.Errorf("init not undone: %q", f.calls)
+	}
+	if loadSettings().ActiveRelayProfile != "globex" || len(f.rules) != 1 || f.rules[0].Name != "web" {
+		t.Errorf("state after rollback: %+v", f.rules)
+	}
+}
+
+func TestRelayProfileLegacyToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	updateSettings(func(s *AppSettings) {
+		s.RelayProfiles = []RelayProfile{{Name: "old", Server: "9.9.9.9:7000", Token: "legacy", Rules: []RelayRuleInfo{}}}
+	})
+	if tok, err := a.relayProfileToken(loadSettings().RelayProfiles[0]); err != nil || tok != "legacy" {
+		t.Errorf("legacy token = %q, %v", tok, err)
+	}
+	a.UnlockVault("correct horse")
+	if msg := a.SaveRelayProfile(RelayProfile{Name: "old", Server: "9.9.9.9:7000"}); msg != "已保存" {
+		t.Fatal(msg)
+	}
+	if p := loadSettings().RelayProfiles[0]; p.Token != "" {
+		t.Errorf("token left in settings: %+v", p)
+	}
+	if tok, _ := a.vault.get(relayTokenKey("9.9.9.9:7000")); tok != "legacy" {
+		t.Errorf("vault token = %q", tok)
+	}
+}
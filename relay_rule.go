@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ==================== 中继规则校验 ====================
+// 添加、修改中继规则前先在本地校验再交给内核。两条规则争用同一远程端口时 frps 只让
+// 先注册的一条生效，另一条静默失败，很难排查，因此与现有规则的端口、域名冲突都直接拒绝。
+// 错误按字段返回，前端可以直接标在对应输入框上。
+
+// minRemotePort: 远程端口由中继服务器上的 frps 监听，低于 1024 需要 root 权限
+const minRemotePort = 1024
+
+var relayProtos = map[string]bool{"tcp": true, "udp": true, "http": true, "https": true}
+
+type RelayFieldError struct {
+	Field   string `json:"field"` // name / proto / local_port / remote_port / domain
+	Message string `json:"message"`
+}
+
+type RelayRuleValidation struct {
+	Rule   RelayRuleInfo     `json:"rule"` // 规范化后的规则
+	Valid  bool              `json:"valid"`
+	Errors []RelayFieldError `json:"errors"`
+	Err    string            `json:"err,omitempty"` // 读取现有规则失败时未做冲突检查
+}
+
+func (v RelayRuleValidation) message() string {
+	msgs := make([]string, len(v.Errors))
+	for i, e := range v.Errors {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "；")
+}
+
+// ValidateRelayRule: 校验单条规则并与当前中继规则比较
+func (a *App) ValidateRelayRule(r RelayRuleInfo) RelayRuleValidation {
+	existing, err := (currentStateReader{}).relayRules()
+	v := checkRelayRule(r, existing)
+	if err != nil {
+		v.Err = err.Error()
+	}
+	return v
+}
+
+func checkRelayRule(r RelayRuleInfo, existing []RelayRuleInfo) RelayRuleValidation {
+	errs := validateRelayRule(&r, existing)
+	return RelayRuleValidation{Rule: r, Valid: len(errs) == 0, Errors: nonNil(errs)}
+}
+
+// validateRelayRule: 规范化 r 并返回字段错误；existing 中与 r 同名的规则视为名称冲突
+func validateRelayRule(r *RelayRuleInfo, existing []RelayRuleInfo) []RelayFieldError {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Proto = strings.ToLower(strings.TrimSpace(r.Proto))
+	r.Domain = strings.ToLower(strings.TrimSpace(r.Domain))
+
+	var errs []RelayFieldError
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, RelayFieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case r.Name == "":
+		fail("name", "名称不能为空")
+	case strings.ContainsAny(r.Name, " \t/\\"):
+		fail("name", "名称不能包含空白或斜杠: %q", r.Name)
+	}
+	if !relayProtos[r.Proto] {
+		fail("proto", "不支持的协议 %q，可选 tcp、udp、http、https", r.Proto)
+	}
+	if r.LocalPort < 1 || r.LocalPort > 65535 {
+		fail("local_port", "本地端口需在 1-65535 之间: %d", r.LocalPort)
+	}
+	vhost := r.Proto == "http" || r.Proto == "https"
+	switch {
+	case r.RemotePort < 0 || r.RemotePort > 65535:
+		fail("remote_port", "远程端口需在 1-65535 之间: %d", r.RemotePort)
+	case !vhost && r.RemotePort > 0 && r.RemotePort < minRemotePort:
+		fail("remote_port", "远程端口 %d 为特权端口，需不小于 %d", r.RemotePort, minRemotePort)
+	}
+	switch {
+	case vhost && r.Domain == "":
+		fail("domain", "%s 规则需要填写域名", r.Proto)
+	case r.Domain != "" && !validHost(r.Domain):
+		fail("domain", "域名无效: %q", r.Domain)
+	}
+
+	for _, e := range existing {
+		switch {
+		case r.Name != "" && e.Name == r.Name:
+			fail("name", "名称已存在: %s", r.Name)
+		case !vhost && r.RemotePort > 0 && e.RemotePort == r.RemotePort && relayTransport(e.Proto) == r.Proto:
+			fail("remote_port", "远程端口 %d 已被规则 %s 使用", r.RemotePort, e.Name)
+		case vhost && r.Domain != "" && strings.EqualFold(e.Domain, r.Domain) && strings.EqualFold(e.Proto, r.Proto):
+			fail("domain", "域名 %s 已被规则 %s 使用", r.Domain, e.Name)
+		}
+	}
+	return errs
+}
+
+// relayTransport: 远程端口所在的传输层协议，http/https 按域名复用 frps 的虚拟主机端口
+func relayTransport(proto string) string {
+	switch p := strings.ToLower(proto); p {
+	case "tcp", "udp":
+		return p
+	}
+	return ""
+}
+
+// ==================== 修改中继规则 ====================
+// 内核支持 relay update 时直接原地修改；否则在新旧规则不争用端口、域名且改了名称时
+// 先添加新规则、确认生效后再删除旧规则，避免中断；其余情况只能先删后加。
+// 任一步失败（包括添加后在 relay list 中查不到新规则）都会撤销已执行的步骤，恢复原规则。
+
+type RelayUpdateResult struct {
+	Rule   RelayRuleInfo     `json:"rule"`
+	Mode   string            `json:"mode"` // native / add-first / replace
+	Errors []RelayFieldError `json:"errors,omitempty"`
+	StepsOutcome
+	Err string `json:"err,omitempty"`
+}
+
+func (a *App) RelayUpdateRule(name string, rule RelayRuleInfo) RelayUpdateResult {
+	return updateRelayRule(name, rule, currentStateReader{}, runCftunnel)
+}
+
+func updateRelayRule(name string, rule RelayRuleInfo, state stateReader, run cftunnelRunner) RelayUpdateResult {
+	current, err := state.relayRules()
+	if err != nil {
+		return RelayUpdateResult{Err: err.Error()}
+	}
+	var old RelayRuleInfo
+	var others []RelayRuleInfo
+	found := false
+	for _, r := range current {
+		if r.Name == name {
+			old, found = r, true
+		} else {
+			others = append(others, r)
+		}
+	}
+	if !found {
+		return RelayUpdateResult{Err: "未找到中继规则 " + name}
+	}
+	v := checkRelayRule(rule, others)
+	res := RelayUpdateResult{Rule: v.Rule}
+	if !v.Valid {
+		res.Errors = v.Errors
+		res.Err = "校验失败: " + v.message()
+		return res
+	}
+	rule = v.Rule
+	// 固定远程端口改为自动分配也是修改；relay update 省略 --remote 时会保留原端口，只能先删后加
+	toAuto := rule.RemotePort == 0 && old.RemotePort != 0
+	if rule.Name == old.Name && relayRuleMatches(old, rule) && !toAuto {
+		return res // 无变化
+	}
+
+	verify := cftunnelStep{Desc: "确认中继规则 " + rule.Name, Check: func() error {
+		rules, err := state.relayRules()
+		if err != nil {
+			return err
+		}
+		for _, r := range rules {
+			if r.Name == rule.Name && relayRuleMatches(r, rule) {
+				return nil
+			}
+		}
+		return errors.New("relay list 中未找到修改后的规则")
+	}}
+	add := cftunnelStep{Desc: "添加中继规则 " + rule.Name, Args: relayAddArgs(rule), Undo: []string{"relay", "remove", rule.Name}}
+	remove := cftunnelStep{Desc: "删除中继规则 " + old.Name, Args: []string{"relay", "remove", old.Name}, Undo: relayAddArgs(old)}
+
+	var steps []cftunnelStep
+	switch {
+	case rule.Name == old.Name && !toAuto && relaySupportsUpdate(run):
+		res.Mode = "native"
+		steps = []cftunnelStep{{Desc: "修改中继规则 " + name, Args: relayUpdateArgs(rule), Undo: relayUpdateArgs(old)}, verify}
+	case rule.Name != old.Name && !relayRulesOverlap(old, rule):
+		res.Mode = "add-first"
+		steps = []cftunnelStep{add, verify, remove}
+	default:
+		res.Mode = "replace"
+		steps = []cftunnelStep{remove, add, verify}
+	}
+	if res.StepsOutcome, err = applySteps(run, steps); err != nil {
+		res.Err = err.Error()
+		if res.RolledBack && len(res.RollbackErrors) == 0 {
+			res.Err += "（已恢复原规则）"
+		}
+	}
+	return res
+}
+
+// relaySupportsUpdate: 旧版内核没有 relay update 子命令，从帮助输出判断
+func relaySupportsUpdate(run cftunnelRunner) bool {
+	out, _ := run("relay", "--help")
+	for _, line := range strings.Split(out, "\n") {
+		if f := strings.Fields(line); len(f) > 0 && f[0] == "update" {
+			return true
+		}
+	}
+	return false
+}
+
+func relayUpdateArgs(r RelayRuleInfo) []string {
+	args := relayAddArgs(r)
+	args[1] = "update"
+	return args
+}
+
+// relayRulesOverlap: 两条规则同时存在时是否争用同一远程端口或域名
+func relayRulesOverlap(a, b RelayRuleInfo) bool {
+	if t := relayTransport(a.Proto); t != "" && t == relayTransport(b.Proto) {
+		return a.RemotePort > 0 && a.RemotePort == b.RemotePort
+	}
+	return a.Domain != "" && strings.EqualFold(a.Proto, b.Proto) && strings.EqualFold(a.Domain, b.Domain)
+}
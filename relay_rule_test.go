@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestValidateRelayRule(t *testing.T) {
+	existing := []RelayRuleInfo{
+		{Name: "mc", Proto: "tcp", LocalPort: 25565, RemotePort: 25565},
+		{Name: "dns", Proto: "udp", LocalPort: 53, RemotePort: 5353},
+		{Name: "blog", Proto: "http", LocalPort: 8080, Domain: "blog.example.com"},
+	}
+	fields := func(r RelayRuleInfo) []string {
+		v := checkRelayRule(r, existing)
+		out := []string{}
+		for _, e := range v.Errors {
+			out = append(out, e.Field)
+		}
+		if v.Valid != (len(out) == 0) {
+			t.Errorf("%+v: valid = %v with errors %v", r, v.Valid, out)
+		}
+		return out
+	}
+
+	cases := []struct {
+		rule RelayRuleInfo
+		want []string
+	}{
+		{RelayRuleInfo{Name: " ssh ", Proto: "TCP", LocalPort: 22, RemotePort: 2222}, []string{}},
+		{RelayRuleInfo{Name: "dns2", Proto: "tcp", LocalPort: 53, RemotePort: 5353}, []string{}}, // TCP 与 UDP 不冲突
+		{RelayRuleInfo{Name: "mc2", Proto: "tcp", LocalPort: 25566, RemotePort: 25565}, []string{"remote_port"}},
+		{RelayRuleInfo{Name: "mc", Proto: "tcp", LocalPort: 25566, RemotePort: 25566}, []string{"name"}},
+		{RelayRuleInfo{Name: "web", Proto: "tcp", LocalPort: 80, RemotePort: 80}, []string{"remote_port"}},
+		{RelayRuleInfo{Name: "x", Proto: "sctp", LocalPort: 0, RemotePort: 70000}, []string{"proto", "local_port", "remote_port"}},
+		{RelayRuleInfo{Name: "site", Proto: "https", LocalPort: 8443}, []string{"domain"}},
+		{RelayRuleInfo{Name: "blog2", Proto: "http", LocalPort: 8081, Domain: "BLOG.example.com"}, []string{"domain"}},
+		{RelayRuleInfo{Name: "a b", Proto: "udp", LocalPort: 1, Domain: "bad..host"}, []string{"name", "domain"}},
+		{RelayRuleInfo{Name: "blog3", Proto: "http", LocalPort: 8082, Domain: "blog3.example.com:80"}, []string{"domain"}},
+	}
+	for _, c := range cases {
+		if got := fields(c.rule); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%+v: fields = %v, want %v", c.rule, got, c.want)
+		}
+	}
+
+	v := checkRelayRule(RelayRuleInfo{Name: " ssh ", Proto: "TCP", LocalPort: 22}, existing)
+	if v.Rule.Name != "ssh" || v.Rule.Proto != "tcp" || v.message() != "" {
+		t.Errorf("normalized = %+v", v)
+	}
+}
+
+func TestPlanDesiredRelayConflict(t *testing.T) {
+	plan, _ := planDesired(`
+relay:
+  rules:
+    - {name: a, proto: tcp, local_port: 22, remote_port: 2222}
+    - {name: b, proto: tcp, local_port: 23, remote_port: 2222}
+`, testState)
+	if plan.Err == "" || len(plan.Errors) != 1 {
+		t.Errorf("errors = %q", plan.Errors)
+	}
+}
+
+// fakeRelay: 模拟内核的中继规则表，同时充当 stateReader 与 cftunnelRunner
+type fakeRelay struct {
+	rules  []RelayRuleInfo
+	update bool   // 是否支持 relay update
+	fail   string // 以此开头的命令返回失败
+	drop   string // 以此开头的命令返回成功但规则未生效
+	server string // relay status 输出的服务器地址
+	calls  []string
+}
+
+func (f *fakeRelay) routes() ([]RouteInfo, error) { return nil, nil }
+func (f *fakeRelay) relayRules() ([]RelayRuleInfo, error) {
+	return append([]RelayRuleInfo(nil), f.rules...), nil
+}
+
+func (f *fakeRelay) run(args ...string) (string, error) {
+	cmd := strings.Join(args, " ")
+	if cmd == "relay --help" {
+		if f.update {
+			return "Available Commands:\n  add\n  remove\n  update\n", nil
+		}
+		return "Available Commands:\n  add\n  remove\n", nil
+	}
+	f.calls = append(f.calls, cmd)
+	if f.fail != "" && strings.HasPrefix(cmd, f.fail) {
+		return "端口被占用", errors.New("exit status 1")
+	}
+	if cmd == "relay status" && f.server != "" {
+		return "服务器: " + f.server + "\n", nil
+	}
+	switch args[1] {
+	case "remove":
+		for i, r := range f.rules {
+			if r.Name == args[2] {
+				f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			}
+		}
+	case "add", "update":
+		r := RelayRuleInfo{Name: args[2]}
+		for i := 3; i+1 < len(args); i += 2 {
+			switch args[i] {
+			case "--proto":
+				r.Proto = args[i+1]
+			case "--local":
+				r.LocalPort, _ = strconv.Atoi(args[i+1])
+			case "--remote":
+				r.RemotePort, _ = strconv.Atoi(args[i+1])
+			case "--domain":
+				r.Domain = args[i+1]
+			}
+		}
+		if args[1] == "update" {
+			for i := range f.rules {
+				if f.rules[i].Name == r.Name {
+					f.rules[i] = r
+				}
+			}
+		} else if f.drop == "" || !strings.HasPrefix(cmd, f.drop) {
+			f.rules = append(f.rules, r)
+		}
+	}
+	return "", nil
+}
+
+func newFakeRelay() *fakeRelay {
+	return &fakeRelay{rules: []RelayRuleInfo{
+		{Name: "mc", Proto: "tcp", LocalPort: 25565, RemotePort: 25565},
+		{Name: "ssh", Proto: "tcp", LocalPort: 22, RemotePort: 2222},
+	}}
+}
+
+func TestUpdateRelayRule(t *testing.T) {
+	// 同名修改、内核不支持 update：先删后加
+	f := newFakeRelay()
+	res := updateRelayRule("ssh", RelayRuleInfo{Name: "ssh", Proto: "tcp", LocalPort: 22, RemotePort: 2223}, f, f.run)
+	if res.Err != "" || res.Mode != "replace" || !reflect.DeepEqual(f.calls, []string{
+		"relay remove ssh",
+		"relay add ssh --proto tcp --local 22 --remote 2223",
+	}) {
+		t.Errorf("replace: %+v %q", res, f.calls)
+	}
+
+	// 内核支持 update：原地修改
+	f = newFakeRelay()
+	f.update = true
+	res = updateRelayRule("ssh", RelayRuleInfo{Name: "ssh", Proto: "tcp", LocalPort: 2022, RemotePort: 2222}, f, f.run)
+	if res.Err != "" || res.Mode != "native" || len(f.calls) != 1 || f.rules[1].LocalPort != 2022 {
+		t.Errorf("native: %+v %q", res, f.calls)
+	}
+
+	// 固定远程端口改为自动分配：即使支持 update 也先删后加
+	f = newFakeRelay()
+	f.update = true
+	res = updateRelayRule("ssh", RelayRuleInfo{Name: "ssh", Proto: "tcp", LocalPort: 22}, f, f.run)
+	if res.Err != "" || res.Mode != "replace" || !reflect.DeepEqual(f.calls, []string{
+		"relay remove ssh",
+		"relay add ssh --proto tcp --local 22",
+	}) {
+		t.Errorf("to auto: %+v %q", res, f.calls)
+	}
+
+	// 改名且端口不冲突：先加后删
+	f = newFakeRelay()
+	res = updateRelayRule("ssh", RelayRuleInfo{Name: "ssh2", Proto: "tcp", LocalPort: 22, RemotePort: 2223}, f, f.run)
+	if res.Err != "" || res.Mode != "add-first" || !reflect.DeepEqual(f.calls, []string{
+		"relay add ssh2 --proto tcp --local 22 --remote 2223",
+		"relay remove ssh",
+	}) {
+		t.Errorf("add-first: %+v %q", res, f.calls)
+	}
+
+	// 与其他规则冲突：不执行任何命令
+	f = newFakeRelay()
+	res = updateRelayRule("ssh", RelayRuleInfo{Name: "ssh", Proto: "tcp", LocalPort: 22, RemotePort: 25565}, f, f.run)
+	if res.Err == "" || len(res.Errors) != 1 || res.Errors[0].Field != "remote_port" || len(f.calls) != 0 {
+		t.Errorf("conflict: %+v %q", res, f.calls)
+	}
+
+	if res := updateRelayRule("nope", RelayRuleInfo{}, f, f.run); res.Err == "" {
+		t.Error("missing rule accepted")
+	}
+}
+
+func TestUpdateRelayRuleRestore(t *testing.T) {
+	orig := newFakeRelay().rules
+
+	// 添加新规则失败：恢复旧规则
+	f := newFakeRelay()
+	f.fail = "relay add ssh --proto tcp --local 22 --remote 2223"
+	res := updateRelayRule("ssh", RelayRuleInfo{Name: "ssh", Proto: "tcp", LocalPort: 22, RemotePort: 2223}, f, f.run)
+	if !res.RolledBack || !strings.Contains(res.Err, "已恢复原规则") || !sameRelayRules(f.rules, orig) {
+		t.Errorf("add failure: %+v rules=%+v", res, f.rules)
+	}
+
+	// 添加返回成功但规则未出现：撤销并恢复
+	f = newFakeRelay()
+	f.drop = "relay add ssh --proto tcp --local 22 --remote 2223"
+	res = updateRelayRule("ssh", RelayRuleInfo{Name: "ssh", Proto: "tcp", LocalPort: 22, RemotePort: 2223}, f, f.run)
+	if !res.RolledBack || !sameRelayRules(f.rules, orig) {
+		t.Errorf("verify failure: %+v rules=%+v calls=%q", res, f.rules, f.calls)
+	}
+}
+
+func sameRelayRules(a, b []RelayRuleInfo) bool {
+	m := map[string]RelayRuleInfo{}
+	for _, r := range a {
+		m[r.Name] = r
+	}
+	if len(m) != len(b) {
+		return false
+	}
+	for _, r := range b {
+		if m[r.Name] != r {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ==================== 请求重放 ====================
+// 把检查器记录的请求（可修改方法、路径、请求头、正文）直接发给本地源站，
+// 不经过 cloudflared 和访问控制，新的响应作为一条 replay_of 记录保存在原请求旁边。
+// 被访问控制拦截的请求不允许重放；记录中（已隐去的）网关凭据不会发给源站。
+
+type ReplayOverrides struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"` // 值为空表示删除该请求头
+	Body    *string           `json:"body"`    // nil 表示沿用原正文
+}
+
+type ReplayResult struct {
+	Request CapturedRequest `json:"request"`
+	Err     string          `json:"err,omitempty"`
+}
+
+func (a *App) ReplayRequest(id int64, overrides ReplayOverrides) ReplayResult {
+	orig, ok := a.requests.get(id)
+	if !ok {
+		return ReplayResult{Err: "记录不存在或已被清理"}
+	}
+	a.quickMu.Lock()
+	origin := a.quickOrigin
+	a.quickMu.Unlock()
+	if origin == nil {
+		return ReplayResult{Err: "没有可用的源站，请先以请求检查模式启动临时隧道"}
+	}
+
+	req, err := buildReplayRequest(orig, overrides)
+	if err != nil {
+		return ReplayResult{Err: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	c := CapturedRequest{
+		Time:        start,
+		Method:      req.Method,
+		Path:        req.URL.RequestURI(),
+		Host:        req.Host,
+		RemoteIP:    "replay",
+		ReqHeaders:  req.Header.Clone(),
+		ReqBodySize: req.ContentLength,
+		ReplayOf:    orig.ID,
+	}
+	c.ReqBody = append([]byte(nil), replayBody(orig, overrides)...)
+
+	sink := &memoryResponse{header: http.Header{}}
+	rec := &recordingWriter{ResponseWriter: sink, body: &capBuffer{limit: inspectorBodyCap}}
+	origin.ServeHTTP(rec, req)
+
+	c.Status = rec.status
+	if c.Status == 0 {
+		c.Status = http.StatusOK
+	}
+	c.RespHeaders = sink.header.Clone()
+	c.RespBody, c.RespBodySize, c.RespBodyTruncated = rec.body.result()
+	c.DurationMS = time.Since(start).Milliseconds()
+
+	c = a.requests.add(c)
+	a.emit("quick:request", c)
+	return ReplayResult{Request: c}
+}
+
+func replayBody(orig CapturedRequest, o ReplayOverrides) []byte {
+	if o.Body != nil {
+		return []byte(*o.Body)
+	}
+	return orig.ReqBody
+}
+
+func buildReplayRequest(orig CapturedRequest, o ReplayOverrides) (*http.Request, error) {
+	if orig.GateRejected {
+		return nil, errors.New("该请求被访问控制拦截，未到达源站，不能重放")
+	}
+	if o.Body == nil && orig.ReqBodyTruncated {
+		return nil, errors.New("原请求正文超过记录上限已被截断，请提供完整正文后再重放")
+	}
+	method := orig.Method
+	if o.Method != "" {
+		method = strings.ToUpper(o.Method)
+	}
+	path := stripGateParam(orig.Path)
+	if o.Path != "" {
+		path = o.Path
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, errors.New("路径必须以 / 开头")
+	}
+
+	body := replayBody(orig, o)
+	req, err := http.NewRequest(method, "http://replay.local"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = orig.ReqHeaders.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	stripGateHeaders(req.Header)
+	for k, v := range o.Headers {
+		if v == "" {
+			req.Header.Del(k)
+		} else {
+			req.Header.Set(k, v)
+		}
+	}
+	req.Header.Del("Content-Length")
+	req.ContentLength = int64(len(body))
+	req.Host = orig.Host
+	req.RemoteAddr = "127.0.0.1:0"
+	return req, nil
+}
+
+// stripGateHeaders: 去掉访问控制消费的凭据（记录时已替换为占位值）
+func stripGateHeaders(h http.Header) {
+	if strings.HasSuffix(h.Get("Authorization"), " "+redactedValue) {
+		h.Del("Authorization")
+	}
+	removeCookie(h, gateTokenParam)
+}
+
+func stripGateParam(path string) string {
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		return path
+	}
+	q := u.Query()
+	if !q.Has(gateTokenParam) {
+		return path
+	}
+	q.Del(gateTokenParam)
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+// memoryResponse: 重放时接收源站响应的内存 ResponseWriter，正文由 recordingWriter 记录
+type memoryResponse struct {
+	header http.Header
+}
+
+func (m *memoryResponse) Header() http.Header         { return m.header }
+func (m *memoryResponse) Write(p []byte) (int, error) { return len(p), nil }
+func (m *memoryResponse) WriteHeader(int)             {}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplayRequest(t *testing.T) {
+	a := NewApp()
+	var seen []string
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		seen = append(seen, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Sig")+" "+string(body))
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, "ok")
+	})
+	a.quickOrigin = origin
+
+	req := httptest.NewRequest("POST", "/hook", strings.NewReader("v1"))
+	req.Header.Set("X-Sig", "abc")
+	a.inspect(origin, nil).ServeHTTP(httptest.NewRecorder(), req)
+
+	body := "v2"
+	res := a.ReplayRequest(1, ReplayOverrides{Headers: map[string]string{"X-Sig": ""}, Body: &body})
+	if res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	if res.Request.ReplayOf != 1 || res.Request.Status != http.StatusAccepted || string(res.Request.RespBody) != "ok" {
+		t.Errorf("replay record = %+v", res.Request)
+	}
+	if len(seen) != 2 || seen[1] != "POST /hook  v2" {
+		t.Errorf("origin saw %q", seen)
+	}
+	if n := len(a.GetQuickRequests()); n != 2 {
+		t.Errorf("log has %d entries, want 2", n)
+	}
+}
+
+func TestReplayRequestErrors(t *testing.T) {
+	a := NewApp()
+	if res := a.ReplayRequest(42, ReplayOverrides{}); res.Err == "" {
+		t.Errorf("missing record should fail")
+	}
+
+	a.requests.add(CapturedRequest{Method: "POST", Path: "/", ReqBodyTruncated: true})
+	a.quickOrigin = http.NotFoundHandler()
+	if res := a.ReplayRequest(1, ReplayOverrides{}); res.Err == "" {
+		t.Errorf("truncated body without override should fail")
+	}
+	if res := a.ReplayRequest(1, ReplayOverrides{Path: "no-slash", Body: new(string)}); res.Err == "" {
+		t.Errorf("relative path should fail")
+	}
+}
+
+func TestReplayRequestGate(t *testing.T) {
+	a := NewApp()
+	var seen []*http.Request
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = append(seen, r) })
+	a.quickOrigin = origin
+	gate := &QuickGate{Token: "tok", AllowCountries: []string{"CN"}}
+	h := a.wrapQuickHandler(origin, gate, true)
+
+	req := httptest.NewRequest("GET", "/x?cftunnel_token=tok&a=1", nil)
+	req.Header.Set("Cf-Ipcountry", "CN")
+	req.Header.Set("Authorization", "Bearer tok")
+	req.Header.Set("Cookie", "session=abc; cftunnel_token=tok")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	res := a.ReplayRequest(1, ReplayOverrides{})
+	if res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	r := seen[len(seen)-1]
+	if r.URL.RequestURI() != "/x?a=1" || r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "session=abc" {
+		t.Errorf("replayed %s %v", r.URL.RequestURI(), r.Header)
+	}
+
+	// 被访问控制拦截的请求不能重放
+	for _, country := range []string{"US", "CN"} {
+		req = httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Cf-Ipcountry", country)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	n := len(seen)
+	for _, c := range a.GetQuickRequests()[2:] {
+		if !c.GateRejected {
+			t.Errorf("request %d not marked as rejected: %d", c.ID, c.Status)
+		}
+		if res := a.ReplayRequest(c.ID, ReplayOverrides{}); res.Err == "" {
+			t.Errorf("replayed rejected request %d", c.ID)
+		}
+	}
+	if len(seen) != n {
+		t.Error("rejected request reached origin")
+	}
+}
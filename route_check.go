@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==================== 路由健康检查 ====================
+// 对每条路由的源站做 TCP 连接检查，配置了路径时再发起 HTTP GET 并校验状态码；
+// 开启公网检查时同时请求 https://<域名>，区分"本地服务没起来"与"隧道/边缘有问题"。
+// Cloudflare 在隧道不可达时返回 502/503/504/530，这些状态视为公网检查失败。
+
+const (
+	minRouteCheckInterval = 30
+	originDialTimeout     = 3 * time.Second
+	originHTTPTimeout     = 5 * time.Second
+	publicHTTPTimeout     = 10 * time.Second
+)
+
+type RouteProbe struct {
+	Route        string `json:"route"`
+	Path         string `json:"path"`          // 非空时对源站与公网域名发起 HTTP GET
+	ExpectStatus int    `json:"expect_status"` // 0 表示任意非 5xx 状态
+}
+
+type RouteCheckInfo struct {
+	Name     string `json:"name"`
+	Hostname string `json:"hostname"`
+	Service  string `json:"service"`
+
+	OriginOK        bool   `json:"origin_ok"`
+	OriginLatencyMS int64  `json:"origin_latency_ms"`
+	OriginStatus    int    `json:"origin_status,omitempty"`
+	OriginErr       string `json:"origin_err"`
+
+	PublicChecked   bool   `json:"public_checked"`
+	PublicOK        bool   `json:"public_ok"`
+	PublicLatencyMS int64  `json:"public_latency_ms"`
+	PublicStatus    int    `json:"public_status,omitempty"`
+	PublicErr       string `json:"public_err"`
+}
+
+type RouteCheckResult struct {
+	Routes    []RouteCheckInfo `json:"routes"`
+	Total     int              `json:"total"`
+	Passed    int              `json:"passed"`
+	Failed    int              `json:"failed"`
+	CheckedAt time.Time        `json:"checked_at"`
+	Err       string           `json:"err,omitempty"`
+}
+
+type RouteCheckScheduleInfo struct {
+	IntervalSeconds int    `json:"interval_seconds"` // 0 表示关闭
+	Public          bool   `json:"public"`
+	Running         bool   `json:"running"`
+	Err             string `json:"err,omitempty"`
+}
+
+func (r RouteCheckInfo) ok() bool {
+	return r.OriginOK && (!r.PublicChecked || r.PublicOK)
+}
+
+// RouteCheck: 立即检查全部路由
+func (a *App) RouteCheck() RouteCheckResult {
+	var res RouteCheckResult
+	if routes, err := listRoutes(); err != nil {
+		res = RouteCheckResult{Routes: []RouteCheckInfo{}, CheckedAt: time.Now(), Err: err.Error()}
+	} else {
+		res = newRouteProber(loadSettings().RouteCheck).check(routes)
+	}
+	a.recordRouteCheck(res)
+	return res
+}
+
+// GetRouteCheck: 最近一次检查结果（手动或定时）
+func (a *App) GetRouteCheck() RouteCheckResult {
+	a.routeMu.Lock()
+	defer a.routeMu.Unlock()
+	return a.routeCheck
+}
+
+func (a *App) recordRouteCheck(res RouteCheckResult) {
+	a.routeMu.Lock()
+	a.routeCheck = res
+	a.routeMu.Unlock()
+	a.observeRouteCheck(res)
+}
+
+// GetRouteProbes / SaveRouteProbes: 按路由名配置 HTTP 检查路径与期望状态码
+func (a *App) GetRouteProbes() []RouteProbe {
+	return nonNil(loadSettings().RouteCheck.Probes)
+}
+
+func (a *App) SaveRouteProbes(probes []RouteProbe) string {
+	seen := map[string]bool{}
+	for _, p := range probes {
+		if p.Route == "" {
+			return "错误: 缺少路由名"
+		}
+		if seen[p.Route] {
+			return "错误: 路由重复: " + p.Route
+		}
+		seen[p.Route] = true
+		if p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+			return "错误: 路径需以 / 开头: " + p.Path
+		}
+		if p.ExpectStatus != 0 && (p.ExpectStatus < 100 || p.ExpectStatus > 599) {
+			return fmt.Sprintf("错误: 状态码无效: %d", p.ExpectStatus)
+		}
+	}
+	if _, err := updateSettings(func(s *AppSettings) { s.RouteCheck.Probes = probes }); err != nil {
+		return "错误: " + err.Error()
+	}
+	return "已保存"
+}
+
+// ---------- 检查实现 ----------
+
+type routeProber struct {
+	probes     map[string]RouteProbe
+	public     bool
+	originHTTP *http.Client
+	publicHTTP *http.Client
+	publicURL  func(hostname, path string) string // 测试中可替换为本地地址
+}
+
+func newRouteProber(cfg RouteCheckSettings) *routeProber {
+	p := &routeProber{
+		probes: map[string]RouteProbe{},
+		public: cfg.Public,
+		originHTTP: &http.Client{
+			Timeout: originHTTPTimeout,
+			Transport: &http.Transport{
+				Proxy:           nil,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // 本地源站常用自签名证书
+			},
+			CheckRedirect: noRedirect,
+		},
+		publicHTTP: &http.Client{Timeout: publicHTTPTimeout, CheckRedirect: noRedirect},
+		publicURL: func(hostname, path string) string {
+			return "https://" + hostname + path
+		},
+	}
+	for _, pr := range cfg.Probes {
+		p.probes[pr.Route] = pr
+	}
+	return p
+}
+
+func noRedirect(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+func (p *routeProber) check(routes []RouteInfo) RouteCheckResult {
+	res := RouteCheckResult{Routes: make([]RouteCheckInfo, len(routes)), CheckedAt: time.Now()}
+	var wg sync.WaitGroup
+	for i, r := range routes {
+		wg.Add(1)
+		go func(i int, r RouteInfo) {
+			defer wg.Done()
+			res.Routes[i] = p.checkRoute(r)
+		}(i, r)
+	}
+	wg.Wait()
+	res.Total = len(routes)
+	for _, r := range res.Routes {
+		if r.ok() {
+			res.Passed++
+		} else {
+			res.Failed++
+		}
+	}
+	return res
+}
+
+func (p *routeProber) checkRoute(r RouteInfo) RouteCheckInfo {
+	info := RouteCheckInfo{Name: r.Name, Hostname: r.Hostname, Service: r.Service}
+	probe := p.probes[r.Name]
+
+	start := time.Now()
+	info.OriginStatus, info.OriginErr = p.checkOrigin(r.Service, probe)
+	info.OriginLatencyMS = time.Since(start).Milliseconds()
+	info.OriginOK = info.OriginErr == ""
+
+	if p.public && r.Hostname != "" {
+		path := probe.Path
+		if path == "" {
+			path = "/"
+		}
+		info.PublicChecked = true
+		start = time.Now()
+		info.PublicStatus, info.PublicErr = probeHTTP(p.publicHTTP, p.publicURL(r.Hostname, path), probe.ExpectStatus, true)
+		info.PublicLatencyMS = time.Since(start).Milliseconds()
+		info.PublicOK = info.PublicErr == ""
+	}
+	return info
+}
+
+// checkOrigin: 先做 TCP 连接；http/https 源站且配置了路径时再发 GET
+func (p *routeProber) checkOrigin(service string, probe RouteProbe) (int, string) {
+	u, err := url.Parse(service)
+	if err != nil || u.Host == "" {
+		return 0, "无法解析服务地址: " + service
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "http":
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		case "https":
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		default:
+			return 0, "服务地址缺少端口: " + service
+		}
+	}
+	conn, err := net.DialTimeout("tcp", addr, originDialTimeout)
+	if err != nil {
+		return 0, err.Error()
+	}
+	conn.Close()
+
+	if probe.Path == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return 0, ""
+	}
+	target := u.Scheme + "://" + addr + probe.Path
+	return probeHTTP(p.originHTTP, target, probe.ExpectStatus, false)
+}
+
+// probeHTTP: 返回状态码与错误描述；public 时把 Cloudflare 的隧道错误状态视为失败
+func probeHTTP(client *http.Client, target string, expect int, public bool) (int, string) {
+	resp, err := client.Get(target)
+	if err != nil {
+		return 0, err.Error()
+	}
+	resp.Body.Close()
+	code := resp.StatusCode
+	switch {
+	case expect != 0 && code != expect:
+		return code, fmt.Sprintf("状态码 %d，期望 %d", code, expect)
+	case expect == 0 && public && (code == 502 || code == 503 || code == 504 || code == 530):
+		return code, fmt.Sprintf("边缘返回 %d，隧道或源站不可达", code)
+	case expect == 0 && code >= 500:
+		return code, fmt.Sprintf("状态码 %d", code)
+	}
+	return code, ""
+}
+
+// ==================== 定时路由检查 ====================
+
+func (a *App) GetRouteCheckSchedule() RouteCheckScheduleInfo {
+	s := loadSettings().RouteCheck
+	a.routeMu.Lock()
+	running := a.routeSchedStop != nil
+	a.routeMu.Unlock()
+	return RouteCheckScheduleInfo{IntervalSeconds: s.IntervalSeconds, Public: s.Public, Running: running}
+}
+
+// SetRouteCheckSchedule: 设置后台检查间隔（秒，0 关闭）以及是否检查公网域名
+func (a *App) SetRouteCheckSchedule(intervalSeconds int, public bool) RouteCheckScheduleInfo {
+	if intervalSeconds != 0 && intervalSeconds < minRouteCheckInterval {
+		return RouteCheckScheduleInfo{Err: fmt.Sprintf("检查间隔不能小于 %d 秒", minRouteCheckInterval)}
+	}
+	s, err := updateSettings(func(s *AppSettings) {
+		s.RouteCheck.IntervalSeconds = intervalSeconds
+		s.RouteCheck.Public = public
+	})
+	if err != nil {
+		return RouteCheckScheduleInfo{Err: "保存设置失败: " + err.Error()}
+	}
+	a.stopRouteScheduler()
+	if intervalSeconds > 0 {
+		a.startRouteScheduler(s.RouteCheck)
+	}
+	return a.GetRouteCheckSchedule()
+}
+
+func (a *App) startRouteScheduler(cfg RouteCheckSettings) {
+	stop := make(chan struct{})
+	a.routeMu.Lock()
+	a.routeSchedStop = stop
+	a.routeMu.Unlock()
+	go a.runRouteScheduler(cfg, stop)
+}
+
+func (a *App) stopRouteScheduler() {
+	a.routeMu.Lock()
+	stop := a.routeSchedStop
+	a.routeSchedStop = nil
+	a.routeMu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+}
+
+// runRouteScheduler: 每轮重新读取探测配置，修改路径后无需重启调度
+func (a *App) runRouteScheduler(cfg RouteCheckSettings, stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		res := a.RouteCheck()
+		a.emit("route:check", res)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteProberCheck(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer origin.Close()
+	edge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/down.") {
+			w.WriteHeader(530)
+		}
+	}))
+	defer edge.Close()
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := ln.Addr().String()
+	ln.Close()
+
+	p := newRouteProber(RouteCheckSettings{
+		Public: true,
+		Probes: []RouteProbe{{Route: "bad", Path: "/bad"}, {Route: "teapot", Path: "/", ExpectStatus: 418}},
+	})
+	p.publicURL = func(hostname, path string) string { return edge.URL + "/" + hostname + path }
+
+	res := p.check([]RouteInfo{
+		{Name: "ok", Hostname: "ok.example.com", Service: origin.URL},
+		{Name: "bad", Hostname: "bad.example.com", Service: origin.URL},
+		{Name: "teapot", Hostname: "teapot.example.com", Service: origin.URL},
+		{Name: "dead", Hostname: "down.example.com", Service: "http://" + closed},
+	})
+	if res.Total != 4 || res.Passed != 1 || res.Failed != 3 {
+		t.Errorf("totals = %d/%d/%d", res.Total, res.Passed, res.Failed)
+	}
+	byName := map[string]RouteCheckInfo{}
+	for _, r := range res.Routes {
+		byName[r.Name] = r
+	}
+	if r := byName["ok"]; !r.OriginOK || !r.PublicOK || !r.PublicChecked {
+		t.Errorf("ok = %+v", r)
+	}
+	if r := byName["bad"]; r.OriginOK || r.OriginStatus != 500 || !r.PublicOK {
+		t.Errorf("bad = %+v", r)
+	}
+	if r := byName["teapot"]; r.OriginOK || !strings.Contains(r.OriginErr, "期望 418") {
+		t.Errorf("teapot = %+v", r)
+	}
+	if r := byName["dead"]; r.OriginOK || r.PublicOK || r.PublicStatus != 530 {
+		t.Errorf("dead = %+v", r)
+	}
+}
+
+func TestSaveRouteProbes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	for _, bad := range [][]RouteProbe{
+		{{Path: "/"}},
+		{{Route: "a", Path: "health"}},
+		{{Route: "a", ExpectStatus: 999}},
+		{{Route: "a"}, {Route: "a"}},
+	} {
+		if msg := a.SaveRouteProbes(bad); msg == "已保存" {
+			t.Errorf("SaveRouteProbes(%+v) accepted", bad)
+		}
+	}
+	if msg := a.SaveRouteProbes([]RouteProbe{{Route: "web", Path: "/healthz", ExpectStatus: 204}}); msg != "已保存" {
+		t.Fatal(msg)
+	}
+	if got := a.GetRouteProbes(); len(got) != 1 || got[0].Path != "/healthz" {
+		t.Errorf("probes = %+v", got)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ==================== 路由导入导出 ====================
+// 路由以 YAML 或 JSON 文档导出，可在其他机器上导入：
+//   merge    新增缺少的路由、更新不一致的路由，保留文档之外的路由
+//   replace  额外删除文档之外的路由，使结果与文档完全一致
+// 导入先计算差异，dry-run 只返回差异不执行，执行中途失败会回滚。内核 add 命令只接受本机端口，
+// 因此路由的服务地址限定为 http://localhost:PORT。
+
+const routeDocVersion = 1
+
+type RouteSpec struct {
+	Name     string `json:"name" yaml:"name"`
+	Hostname string `json:"hostname" yaml:"hostname"`
+	Service  string `json:"service,omitempty" yaml:"service,omitempty"` // http://localhost:PORT，与 port 二选一
+	Port     int    `json:"port,omitempty" yaml:"port,omitempty"`
+}
+
+type RouteDocument struct {
+	Version int         `json:"version" yaml:"version"`
+	Routes  []RouteSpec `json:"routes" yaml:"routes"`
+}
+
+type RouteChange struct {
+	Action string     `json:"action"` // add / change / remove
+	Name   string     `json:"name"`
+	From   *RouteSpec `json:"from,omitempty"`
+	To     *RouteSpec `json:"to,omitempty"`
+}
+
+type RouteExport struct {
+	Format string `json:"format"`
+	Data   string `json:"data"`
+	Err    string `json:"err,omitempty"`
+}
+
+type RouteImportResult struct {
+	Mode    string        `json:"mode"`
+	DryRun  bool          `json:"dry_run"`
+	Changes []RouteChange `json:"changes"`
+	Errors  []string      `json:"errors,omitempty"` // 文档校验错误
+	StepsOutcome
+	Err string `json:"err,omitempty"`
+}
+
+// normalize: 由 service 推出 port，或由 port 补全 service
+func (r *RouteSpec) normalize() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Hostname = strings.ToLower(strings.TrimSpace(r.Hostname))
+	r.Service = strings.TrimSpace(r.Service)
+	if r.Service != "" {
+		port, err := localServicePort(r.Service)
+		if err != nil {
+			return err
+		}
+		if r.Port != 0 && r.Port != port {
+			return fmt.Errorf("port %d 与 service %s 不一致", r.Port, r.Service)
+		}
+		r.Port = port
+	}
+	if r.Port < 1 || r.Port > 65535 {
+		return fmt.Errorf("端口无效: %d", r.Port)
+	}
+	r.Service = "http://localhost:" + strconv.Itoa(r.Port)
+	return nil
+}
+
+func (r RouteSpec) validate() error {
+	if r.Name == "" || strings.ContainsAny(r.Name, " \t/\\") {
+		return fmt.Errorf("名称无效: %q", r.Name)
+	}
+	if !validHost(r.Hostname) || !strings.Contains(r.Hostname, ".") || strings.Contains(r.Hostname, ":") {
+		return fmt.Errorf("域名无效: %q", r.Hostname)
+	}
+	return nil
+}
+
+// localServicePort: 只接受 http://localhost|127.0.0.1:PORT
+func localServicePort(service string) (int, error) {
+	u, err := url.Parse(service)
+	if err != nil || u.Scheme != "http" || (u.Path != "" && u.Path != "/") {
+		return 0, fmt.Errorf("服务地址需为 http://localhost:PORT: %q", service)
+	}
+	if h := u.Hostname(); h != "localhost" && h != "127.0.0.1" {
+		return 0, fmt.Errorf("仅支持本机服务: %q", service)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return 0, fmt.Errorf("服务地址缺少端口: %q", service)
+	}
+	return port, nil
+}
+
+func routeSpecFromInfo(r RouteInfo) RouteSpec {
+	s := RouteSpec{Name: r.Name, Hostname: r.Hostname, Service: r.Service}
+	if port, err := localServicePort(r.Service); err == nil {
+		s.Port = port
+	}
+	return s
+}
+
+// ExportRoutes: format 为 yaml（默认）或 json
+func (a *App) ExportRoutes(format string) RouteExport {
+	routes, err := listRoutes()
+	if err != nil {
+		return RouteExport{Err: err.Error()}
+	}
+	doc := RouteDocument{Version: routeDocVersion, Routes: []RouteSpec{}}
+	for _, r := range routes {
+		doc.Routes = append(doc.Routes, routeSpecFromInfo(r))
+	}
+	data, format, err := encodeDocument(doc, format)
+	if err != nil {
+		return RouteExport{Err: err.Error()}
+	}
+	return RouteExport{Format: format, Data: data}
+}
+
+// encodeDocument: 按 yaml/json 编码，返回实际使用的格式
+func encodeDocument(v any, format string) (string, string, error) {
+	switch strings.ToLower(format) {
+	case "", "yaml", "yml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return "", "", err
+		}
+		return buf.String(), "yaml", nil
+	case "json":
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data) + "\n", "json", err
+	}
+	return "", "", fmt.Errorf("不支持的格式 %q", format)
+}
+
+// ImportRoutes: mode 为 merge（默认）或 replace；dryRun 时只返回差异
+func (a *App) ImportRoutes(data, mode string, dryRun bool) RouteImportResult {
+	current, err := listRoutes()
+	if err != nil {
+		return RouteImportResult{Err: err.Error()}
+	}
+	return importRoutes(data, mode, dryRun, current, runCftunnel)
+}
+
+func importRoutes(data, mode string, dryRun bool, current []RouteInfo, run cftunnelRunner) RouteImportResult {
+	if mode == "" {
+		mode = "merge"
+	}
+	res := RouteImportResult{Mode: mode, DryRun: dryRun, Changes: []RouteChange{}}
+	if mode != "merge" && mode != "replace" {
+		res.Err = "不支持的导入模式: " + mode
+		return res
+	}
+	var doc RouteDocument
+	if err := decodeDocument(data, &doc, func() any { return &doc.Routes }); err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	if doc.Version > routeDocVersion {
+		res.Err = fmt.Sprintf("不支持的文档版本 %d", doc.Version)
+		return res
+	}
+	if res.Errors = validateRouteSpecs(doc.Routes); len(res.Errors) > 0 {
+		res.Err = "文档校验失败"
+		return res
+	}
+	res.Changes = diffRoutes(current, doc.Routes, mode == "replace")
+	if dryRun || len(res.Changes) == 0 {
+		return res
+	}
+	var err error
+	if res.StepsOutcome, err = applySteps(run, routeSteps(res.Changes)); err != nil {
+		res.Err = err.Error()
+	}
+	return res
+}
+
+// decodeDocument: 解析 YAML 或 JSON（JSON 是 YAML 的子集）；顶层为列表时解码到 list()，
+// list 为 nil 表示不接受列表
+func decodeDocument(data string, doc any, list func() any) error {
+	if strings.TrimSpace(data) == "" {
+		return errors.New("文档为空")
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &root); err != nil {
+		return fmt.Errorf("文档解析失败: %v", err)
+	}
+	target := doc
+	if len(root.Content) > 0 && root.Content[0].Kind == yaml.SequenceNode {
+		if list == nil {
+			return errors.New("文档顶层需为对象")
+		}
+		target = list()
+	}
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(target); err != nil {
+		return fmt.Errorf("文档解析失败: %v", err)
+	}
+	return nil
+}
+
+// validateRouteSpecs: 规范化并校验，返回全部错误而不是遇到第一个就停止
+func validateRouteSpecs(routes []RouteSpec) []string {
+	var errs []string
+	names, hosts := map[string]bool{}, map[string]bool{}
+	for i := range routes {
+		r := &routes[i]
+		err := r.normalize()
+		if err == nil {
+			err = r.validate()
+		}
+		if err == nil && names[r.Name] {
+			err = errors.New("名称重复")
+		}
+		if err == nil && hosts[r.Hostname] {
+			err = fmt.Errorf("域名 %s 重复", r.Hostname)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("第 %d 条路由 %s: %v", i+1, r.Name, err))
+			continue
+		}
+		names[r.Name], hosts[r.Hostname] = true, true
+	}
+	return errs
+}
+
+// diffRoutes: 按名称比较；replace 时列出文档之外需要删除的路由
+func diffRoutes(current []RouteInfo, desired []RouteSpec, replace bool) []RouteChange {
+	have := map[string]RouteSpec{}
+	for _, r := range current {
+		have[r.Name] = routeSpecFromInfo(r)
+	}
+	changes := []RouteChange{}
+	want := map[string]bool{}
+	for _, d := range desired {
+		d := d
+		want[d.Name] = true
+		cur, ok := have[d.Name]
+		switch {
+		case !ok:
+			changes = append(changes, RouteChange{Action: "add", Name: d.Name, To: &d})
+		case !strings.EqualFold(cur.Hostname, d.Hostname) || cur.Port != d.Port:
+			cur := cur
+			changes = append(changes, RouteChange{Action: "change", Name: d.Name, From: &cur, To: &d})
+		}
+	}
+	if replace {
+		for _, r := range current {
+			if !want[r.Name] {
+				cur := have[r.Name]
+				changes = append(changes, RouteChange{Action: "remove", Name: r.Name, From: &cur})
+			}
+		}
+	}
+	// 先删除、再修改、最后新增，避免域名冲突
+	order := map[string]int{"remove": 0, "change": 1, "add": 2}
+	sort.SliceStable(changes, func(i, j int) bool { return order[changes[i].Action] < order[changes[j].Action] })
+	return changes
+}
+
+// routeSteps: 把差异转为内核命令；修改通过删除后重新添加完成
+func routeSteps(changes []RouteChange) []cftunnelStep {
+	var steps []cftunnelStep
+	for _, c := range changes {
+		if c.From != nil {
+			step := cftunnelStep{Desc: "删除路由 " + c.Name, Args: []string{"remove", c.Name}}
+			if c.From.Port > 0 { // 非本机服务的路由无法通过 add 恢复
+				step.Undo = routeAddArgs(*c.From)
+			}
+			steps = append(steps, step)
+		}
+		if c.To != nil {
+			steps = append(steps, cftunnelStep{
+				Desc: "添加路由 " + c.Name,
+				Args: routeAddArgs(*c.To),
+				Undo: []string{"remove", c.Name},
+			})
+		}
+	}
+	return steps
+}
+
+func routeAddArgs(r RouteSpec) []string {
+	return []string{"add", r.Name, strconv.Itoa(r.Port), "--domain", r.Hostname}
+}
+
+// listRoutes: 与 GetRoutes 相同，但保留错误，避免把读取失败当成没有路由
+func listRoutes() ([]RouteInfo, error) {
+	out, err := runCftunnel("list")
+	if err != nil {
+		return nil, fmt.Errorf("读取路由失败: %v: %s", err, strings.TrimSpace(out))
+	}
+	return parseRoutes(out), nil
+}
+
+// ---------- 内核命令执行 ----------
+
+// cftunnelRunner: 执行一条内核命令，测试中可替换
+type cftunnelRunner func(args ...string) (string, error)
+
+type cftunnelStep struct {
+	Desc  string
+	Args  []string
+	Undo  []string     // 撤销该步骤的命令
+	Check func() error // 非空时不执行命令，只校验前面步骤的结果，无需撤销
+}
+
+type StepsOutcome struct {
+	Applied        int      `json:"applied"` // 成功执行的命令数（回滚前）
+	Output         []string `json:"output,omitempty"`
+	RolledBack     bool     `json:"rolled_back,omitempty"`
+	RollbackErrors []string `json:"rollback_errors,omitempty"`
+}
+
+// applySteps: 顺序执行；某一步失败时按相反顺序撤销已执行的步骤，尽量回到执行前的状态
+func applySteps(run cftunnelRunner, steps []cftunnelStep) (StepsOutcome, error) {
+	var res StepsOutcome
+	for i, s := range steps {
+		var out string
+		var err error
+		if s.Check != nil {
+			err = s.Check()
+		} else {
+			out, err = run(s.Args...)
+		}
+		if out = strings.TrimSpace(out); out != "" {
+			res.Output = append(res.Output, out)
+		}
+		if err == nil {
+			if s.Check == nil {
+				res.Applied++
+			}
+			continue
+		}
+		failed := fmt.Errorf("%s 失败: %v", s.Desc, err)
+		if out != "" {
+			failed = fmt.Errorf("%s 失败: %v: %s", s.Desc, err, out)
+		}
+		res.RolledBack = i > 0
+		for j := i - 1; j >= 0; j-- {
+			undo := steps[j]
+			if undo.Check != nil {
+				continue
+			}
+			if undo.Undo == nil {
+				res.RollbackErrors = append(res.RollbackErrors, "无法撤销: "+undo.Desc)
+				continue
+			}
+			if out, err := run(undo.Undo...); err != nil {
+				res.RollbackErrors = append(res.RollbackErrors, fmt.Sprintf("撤销 %s 失败: %v: %s", undo.Desc, err, strings.TrimSpace(out)))
+			}
+		}
+		return res, failed
+	}
+	return res, nil
+}
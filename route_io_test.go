@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportRoutesDiff(t *testing.T) {
+	current := []RouteInfo{
+		{Name: "web", Hostname: "web.example.com", Service: "http://localhost:3000"},
+		{Name: "api", Hostname: "api.example.com", Service: "http://localhost:8080"},
+		{Name: "old", Hostname: "old.example.com", Service: "http://localhost:9000"},
+	}
+	doc := `
+version: 1
+routes:
+  - name: web
+    hostname: WEB.example.com
+    service: http://localhost:3000
+  - name: api
+    hostname: api.example.com
+    port: 8081
+  - name: docs
+    hostname: docs.example.com
+    port: 4000
+`
+	var calls [][]string
+	run := func(args ...string) (string, error) {
+		calls = append(calls, args)
+		return "ok", nil
+	}
+
+	res := importRoutes(doc, "merge", true, current, run)
+	if res.Err != "" || len(calls) != 0 {
+		t.Fatalf("dry run: %+v, calls %v", res, calls)
+	}
+	if got := changeActions(res.Changes); !reflect.DeepEqual(got, []string{"change api", "add docs"}) {
+		t.Errorf("merge changes = %v", got)
+	}
+
+	res = importRoutes(doc, "replace", false, current, run)
+	if res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	if got := changeActions(res.Changes); !reflect.DeepEqual(got, []string{"remove old", "change api", "add docs"}) {
+		t.Errorf("replace changes = %v", got)
+	}
+	want := [][]string{
+		{"remove", "old"},
+		{"remove", "api"},
+		{"add", "api", "8081", "--domain", "api.example.com"},
+		{"add", "docs", "4000", "--domain", "docs.example.com"},
+	}
+	if !reflect.DeepEqual(calls, want) || res.Applied != 4 {
+		t.Errorf("calls = %v, applied %d", calls, res.Applied)
+	}
+}
+
+func TestImportRoutesJSONAndErrors(t *testing.T) {
+	failing := func(args ...string) (string, error) {
+		if args[0] == "add" {
+			return "域名已存在", errors.New("exit status 1")
+		}
+		return "", nil
+	}
+	res := importRoutes(`[{"name":"a","hostname":"a.example.com","port":1000},{"name":"b","hostname":"b.example.com","port":2000}]`, "", false, nil, failing)
+	if res.Mode != "merge" || res.Applied != 0 || !strings.Contains(res.Err, "添加路由 a") {
+		t.Errorf("res = %+v", res)
+	}
+
+	res = importRoutes(`
+routes:
+  - {name: a, hostname: a.example.com, port: 1}
+  - {name: a, hostname: b.example.com, port: 2}
+  - {name: c, hostname: c.example.com, service: "http://10.0.0.1:80"}
+  - {name: d, hostname: nodot, port: 3}
+  - {name: e, hostname: a.example.com, port: 4}
+`, "merge", true, nil, nil)
+	if len(res.Errors) != 4 {
+		t.Errorf("errors = %q", res.Errors)
+	}
+
+	for _, bad := range []string{"", "routes: [{name: a, hostnme: a.example.com, port: 1}]", "version: 9\nroutes: []"} {
+		if res := importRoutes(bad, "merge", true, nil, nil); res.Err == "" {
+			t.Errorf("importRoutes(%q) succeeded", bad)
+		}
+	}
+	if res := importRoutes("[]", "overwrite", true, nil, nil); res.Err == "" {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestEncodeRouteDocument(t *testing.T) {
+	doc := RouteDocument{Version: 1, Routes: []RouteSpec{routeSpecFromInfo(RouteInfo{Name: "web", Hostname: "web.example.com", Service: "http://localhost:3000"})}}
+	for _, format := range []string{"yaml", "json"} {
+		data, got, err := encodeDocument(doc, format)
+		if err != nil || got != format {
+			t.Fatalf("encodeDocument(%s) = %q, %v", format, got, err)
+		}
+		var back RouteDocument
+		if err := decodeDocument(data, &back, func() any { return &back.Routes }); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(back, doc) {
+			t.Errorf("%s round trip = %+v", format, back)
+		}
+	}
+}
+
+func changeActions(changes []RouteChange) []string {
+	var out []string
+	for _, c := range changes {
+		out = append(out, c.Action+" "+c.Name)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// AppSettings: 桌面端自身的配置，独立于 cftunnel 内核的 config，存放在状态目录
+type AppSettings struct {
+	API          APISettings        `json:"api"`
+	Metrics      MetricsSettings    `json:"metrics"`
+	RelayCheck   RelayCheckSettings `json:"relay_check"`
+	RouteCheck   RouteCheckSettings `json:"route_check"`
+	Alerts       AlertSettings      `json:"alerts"`
+	URLHooks     []URLHook          `json:"url_hooks"`
+	URLProviders []URLProvider      `json:"url_providers"`
+	QuickPresets []QuickPreset      `json:"quick_presets"`
+
+	RelayProfiles      []RelayProfile `json:"relay_profiles"`
+	ActiveRelayProfile string         `json:"active_relay_profile"`
+}
+
+type APISettings struct {
+	Enabled bool `json:"enabled"`
+	Port    int  `json:"port"`
+}
+
+type MetricsSettings struct {
+	Enabled bool `json:"enabled"`
+	Port    int  `json:"port"`
+}
+
+type RelayCheckSettings struct {
+	IntervalSeconds int `json:"interval_seconds"` // 0 表示不做后台检查
+	RetentionDays   int `json:"retention_days"`   // 0 表示默认 30 天
+}
+
+func (s RelayCheckSettings) retentionDays() int {
+	if s.RetentionDays <= 0 {
+		return 30
+	}
+	return s.RetentionDays
+}
+
+type RouteCheckSettings struct {
+	IntervalSeconds int          `json:"interval_seconds"` // 0 表示不做后台检查
+	Public          bool         `json:"public"`           // 同时检查公网域名
+	Probes          []RouteProbe `json:"probes,omitempty"`
+}
+
+var settingsMu sync.Mutex
+
+func settingsPath() string {
+	return filepath.Join(stateDir(), "app-settings.json")
+}
+
+// loadSettings: 文件损坏时返回能解析出的部分，写回由 updateSettings 拒绝
+func loadSettings() AppSettings {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	s, _ := readSettings()
+	return s
+}
+
+// updateSettings: 读取-修改-写回，整个过程持锁，避免并发覆盖；
+// 文件无法解析时不写回，以免用空设置覆盖；先写临时文件再改名，中途退出不会留下半个文件
+func updateSettings(fn func(s *AppSettings)) (AppSettings, error) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	s, err := readSettings()
+	if err != nil {
+		return s, err
+	}
+	fn(&s)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return s, err
+	}
+	_ = os.MkdirAll(stateDir(), 0700)
+	tmp := settingsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return s, err
+	}
+	return s, os.Rename(tmp, settingsPath())
+}
+
+func readSettings() (AppSettings, error) {
+	var s AppSettings
+	data, err := os.ReadFile(settingsPath())
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("设置文件 %s 损坏: %v", settingsPath(), err)
+	}
+	return s, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUpdateSettingsCorruptFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if _, err := updateSettings(func(s *AppSettings) { s.ActiveRelayProfile = "acme" }); err != nil {
+		t.Fatal(err)
+	}
+	if loadSettings().ActiveRelayProfile != "acme" {
+		t.Fatal("settings not saved")
+	}
+
+	// 损坏的文件不会被空设置覆盖
+	corrupt := []byte(`{"active_relay_profile": "acme", "quick_pre`)
+	if err := os.WriteFile(settingsPath(), corrupt, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := updateSettings(func(s *AppSettings) { s.API.Enabled = true }); err == nil {
+		t.Error("updated corrupt settings")
+	}
+	if data, _ := os.ReadFile(settingsPath()); string(data) != string(corrupt) {
+		t.Errorf("settings file overwritten: %s", data)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// ==================== 二维码与分享 ====================
+// 为临时隧道地址或路由域名生成二维码（PNG/SVG）与可直接粘贴的分享文本。
+// target 为空表示当前临时隧道；临时隧道启用了访问令牌时，链接中会附带令牌，
+// 扫码打开后由访问控制写入 Cookie。
+
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 1024
+)
+
+type ShareInfo struct {
+	URL       string `json:"url"`
+	Link      string `json:"link"` // 分享用链接，可能带访问令牌
+	ExpiresAt string `json:"expires_at,omitempty"`
+	Token     string `json:"token,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+	Message   string `json:"message"`
+	Err       string `json:"err,omitempty"`
+}
+
+type QRCodeInfo struct {
+	Link    string `json:"link"`
+	Format  string `json:"format"` // png / svg
+	MIME    string `json:"mime"`
+	Data    []byte `json:"-"`
+	DataURL string `json:"data_url"` // 可直接用作 <img src>
+	Err     string `json:"err,omitempty"`
+}
+
+// ShareMessage: 生成分享信息；target 为空表示当前临时隧道，否则为完整地址或路由域名
+func (a *App) ShareMessage(target string) ShareInfo {
+	info, err := a.buildShare(target, time.Now())
+	if err != nil {
+		return ShareInfo{Err: err.Error()}
+	}
+	return info
+}
+
+// QRCode: 生成分享链接的二维码，format 为 png（默认）或 svg，size 为像素边长
+func (a *App) QRCode(target, format string, size int) QRCodeInfo {
+	info, err := a.buildShare(target, time.Now())
+	if err != nil {
+		return QRCodeInfo{Err: err.Error()}
+	}
+	qr, err := encodeQRCode(info.Link, format, size)
+	if err != nil {
+		return QRCodeInfo{Err: err.Error()}
+	}
+	return qr
+}
+
+func (a *App) buildShare(target string, now time.Time) (ShareInfo, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return a.buildQuickShare(now)
+	}
+	u, err := shareTargetURL(target)
+	if err != nil {
+		return ShareInfo{}, err
+	}
+	return ShareInfo{URL: u, Link: u, Message: u}, nil
+}
+
+// shareTargetURL: 完整地址原样使用，域名补全为 https
+func shareTargetURL(target string) (string, error) {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("地址无效: %s", target)
+		}
+		return target, nil
+	}
+	if !validHost(target) {
+		return "", fmt.Errorf("域名无效: %s", target)
+	}
+	return "https://" + target, nil
+}
+
+func (a *App) buildQuickShare(now time.Time) (ShareInfo, error) {
+	status := a.QuickStatus()
+	if !status.Running || status.URL == "" {
+		return ShareInfo{}, errors.New("临时隧道未运行")
+	}
+	a.quickMu.Lock()
+	gate := a.quickGate
+	a.quickMu.Unlock()
+
+	info := ShareInfo{URL: status.URL, Link: status.URL}
+	lines := []string{"临时隧道地址: " + status.URL}
+	if gate != nil && gate.Token != "" {
+		info.Token = gate.Token
+		info.Link = status.URL + "/?" + gateTokenParam + "=" + url.QueryEscape(gate.Token)
+		lines[0] = "临时隧道地址: " + info.Link
+		lines = append(lines, "访问令牌: "+gate.Token+"（已包含在链接中）")
+	}
+	if gate != nil && gate.basic() {
+		info.Username, info.Password = gate.Username, gate.Password
+		lines = append(lines, fmt.Sprintf("用户名: %s  密码: %s", gate.Username, gate.Password))
+	}
+	if status.RemainingSeconds >= 0 {
+		remaining := time.Duration(status.RemainingSeconds) * time.Second
+		expires := now.Add(remaining)
+		info.ExpiresAt = expires.Format(time.RFC3339)
+		lines = append(lines, fmt.Sprintf("有效期至: %s（剩余 %s）", expires.Format("2006-01-02 15:04"), formatShareDuration(remaining)))
+	}
+	if status.IdleSeconds > 0 {
+		lines = append(lines, fmt.Sprintf("空闲 %s后自动停止", formatShareDuration(time.Duration(status.IdleSeconds)*time.Second)))
+	}
+	info.Message = strings.Join(lines, "\n")
+	return info, nil
+}
+
+// formatShareDuration: 1 小时 20 分钟 / 5 分钟 / 30 秒
+func formatShareDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	switch {
+	case h > 0 && m > 0:
+		return fmt.Sprintf("%d 小时 %d 分钟", h, m)
+	case h > 0:
+		return fmt.Sprintf("%d 小时", h)
+	case m > 0:
+		return fmt.Sprintf("%d 分钟", m)
+	}
+	return fmt.Sprintf("%d 秒", s)
+}
+
+func encodeQRCode(content, format string, size int) (QRCodeInfo, error) {
+	if size == 0 {
+		size = defaultQRSize
+	}
+	if size < minQRSize || size > maxQRSize {
+		return QRCodeInfo{}, fmt.Errorf("尺寸需在 %d-%d 之间", minQRSize, maxQRSize)
+	}
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return QRCodeInfo{}, fmt.Errorf("生成二维码失败: %v", err)
+	}
+	info := QRCodeInfo{Link: content, Format: strings.ToLower(format)}
+	switch info.Format {
+	case "", "png":
+		info.Format, info.MIME = "png", "image/png"
+		if info.Data, err = qr.PNG(size); err != nil {
+			return QRCodeInfo{}, fmt.Errorf("生成二维码失败: %v", err)
+		}
+	case "svg":
+		info.MIME = "image/svg+xml"
+		info.Data = qrSVG(qr.Bitmap(), size)
+	default:
+		return QRCodeInfo{}, fmt.Errorf("不支持的格式 %q", format)
+	}
+	info.DataURL = "data:" + info.MIME + ";base64," + base64.StdEncoding.EncodeToString(info.Data)
+	return info, nil
+}
+
+// qrSVG: 每个深色模块输出一个单位方格，viewBox 按模块数缩放到 size 像素
+func qrSVG(bitmap [][]bool, size int) []byte {
+	n := len(bitmap)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return []byte(b.String())
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQRCodeFormats(t *testing.T) {
+	a := NewApp()
+
+	qr := a.QRCode("demo.example.com", "png", 200)
+	if qr.Err != "" {
+		t.Fatal(qr.Err)
+	}
+	img, err := png.Decode(bytes.NewReader(qr.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 200 {
+		t.Errorf("png size = %v", b)
+	}
+	if qr.Link != "https://demo.example.com" || !strings.HasPrefix(qr.DataURL, "data:image/png;base64,") {
+		t.Errorf("qr = %+v", qr)
+	}
+
+	svg := a.QRCode("https://x.trycloudflare.com", "svg", 0)
+	if svg.Err != "" || !strings.HasPrefix(string(svg.Data), "<svg") || !strings.Contains(string(svg.Data), `width="256"`) {
+		t.Errorf("svg = %q, err %q", svg.Data, svg.Err)
+	}
+
+	for _, bad := range []QRCodeInfo{a.QRCode("bad host!", "png", 0), a.QRCode("a.com", "gif", 0), a.QRCode("a.com", "png", 5000)} {
+		if bad.Err == "" {
+			t.Errorf("expected error, got %+v", bad)
+		}
+	}
+}
+
+func TestQuickShareMessage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	if info := a.ShareMessage(""); info.Err == "" {
+		t.Fatalf("share without tunnel = %+v", info)
+	}
+
+	now := time.Now()
+	a.quickCmd = &exec.Cmd{}
+	a.quickURL = "https://abc.trycloudflare.com"
+	a.quickGate = &QuickGate{Token: "t k"}
+	a.quickLimits = QuickLimits{TTLSeconds: 3600, IdleSeconds: 600}
+	a.quickStarted = now
+	a.touchQuick()
+
+	info, err := a.buildShare("", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Link != "https://abc.trycloudflare.com/?cftunnel_token=t+k" || info.Token != "t k" {
+		t.Errorf("link = %q token = %q", info.Link, info.Token)
+	}
+	for _, want := range []string{info.Link, "访问令牌: t k", "有效期至: ", "空闲 10 分钟后自动停止"} {
+		if !strings.Contains(info.Message, want) {
+			t.Errorf("message missing %q:\n%s", want, info.Message)
+		}
+	}
+	if info.ExpiresAt == "" {
+		t.Error("ExpiresAt empty")
+	}
+}
+
+func TestFormatShareDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		80 * time.Minute: "1 小时 20 分钟",
+		2 * time.Hour:    "2 小时",
+		5 * time.Minute:  "5 分钟",
+		30 * time.Second: "30 秒",
+	}
+	for d, want := range tests {
+		if got := formatShareDuration(d); got != want {
+			t.Errorf("formatShareDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
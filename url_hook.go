@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ==================== 临时隧道地址变更 Webhook ====================
+// cloudflared 每次启动都会分配新的 trycloudflare.com 地址。scanQuickURL 解析到新地址后，
+// 按配置向外部系统发送请求：请求体由 text/template 渲染（提供 json 函数做转义），
+// 配置了 secret 时附带 X-Cftunnel-Signature: sha256=<HMAC-SHA256(body)>。
+
+const (
+	URLEventAssigned = "assigned" // 本次运行首次获得地址
+	URLEventChanged  = "changed"  // 地址与上一次不同
+
+	urlHookSignatureHeader = "X-Cftunnel-Signature"
+	urlHookEventHeader     = "X-Cftunnel-Event"
+)
+
+// defaultURLHookBody: 未配置模板时的请求体
+const defaultURLHookBody = `{"event":{{json .Event}},"url":{{json .URL}},"previous_url":{{json .PreviousURL}},"time":{{json .Time}}}`
+
+type URLHook struct {
+	Name     string            `json:"name"`
+	URL      string            `json:"url"`
+	Method   string            `json:"method"` // 默认 POST
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     string            `json:"body,omitempty"` // text/template，为空时使用默认 JSON
+	Secret   string            `json:"secret,omitempty"`
+	Disabled bool              `json:"disabled"`
+}
+
+// URLEvent: 模板数据
+type URLEvent struct {
+	Event       string    `json:"event"`
+	URL         string    `json:"url"`
+	PreviousURL string    `json:"previous_url"`
+	Time        time.Time `json:"time"`
+}
+
+type URLHookResult struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Err    string `json:"err,omitempty"`
+}
+
+var urlHookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func (h URLHook) method() string {
+	if h.Method == "" {
+		return http.MethodPost
+	}
+	return strings.ToUpper(h.Method)
+}
+
+func (h URLHook) template() (*template.Template, error) {
+	body := h.Body
+	if body == "" {
+		body = defaultURLHookBody
+	}
+	return template.New(h.Name).Funcs(urlHookFuncs).Option("missingkey=error").Parse(body)
+}
+
+func (h URLHook) Validate() error {
+	if h.Name == "" {
+		return errors.New("webhook 需要名称")
+	}
+	if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+		return fmt.Errorf("%s: 地址无效", h.Name)
+	}
+	switch h.method() {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("%s: 不支持的请求方法 %s", h.Name, h.Method)
+	}
+	if _, err := h.render(sampleURLEvent()); err != nil {
+		return fmt.Errorf("%s: %v", h.Name, err)
+	}
+	return nil
+}
+
+// render: 渲染请求体；未自定义 Content-Type 时要求结果是合法 JSON
+func (h URLHook) render(ev URLEvent) ([]byte, error) {
+	tmpl, err := h.template()
+	if err != nil {
+		return nil, fmt.Errorf("模板错误: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ev); err != nil {
+		return nil, fmt.Errorf("模板错误: %v", err)
+	}
+	if h.header("Content-Type") == "" && !json.Valid(buf.Bytes()) {
+		return nil, errors.New("模板渲染结果不是合法 JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+func (h URLHook) header(name string) string {
+	for k, v := range h.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+func sampleURLEvent() URLEvent {
+	return URLEvent{
+		Event:       URLEventChanged,
+		URL:         "https://example-new.trycloudflare.com",
+		PreviousURL: "https://example-old.trycloudflare.com",
+		Time:        time.Now(),
+	}
+}
+
+// signURLHook: 十六进制 HMAC-SHA256，格式与 GitHub 的 X-Hub-Signature-256 一致
+func signURLHook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (a *App) GetURLHooks() []URLHook {
+	return nonNil(loadSettings().URLHooks)
+}
+
+func (a *App) SaveURLHooks(hooks []URLHook) string {
+	seen := map[string]bool{}
+	for _, h := range hooks {
+		if err := h.Validate(); err != nil {
+			return "错误: " + err.Error()
+		}
+		if seen[h.Name] {
+			return "错误: webhook 重名: " + h.Name
+		}
+		seen[h.Name] = true
+	}
+	if _, err := updateSettings(func(s *AppSettings) { s.URLHooks = hooks }); err != nil {
+		return "错误: " + err.Error()
+	}
+	return "已保存"
+}
+
+// TestURLHook: 用示例地址同步发送一次
+func (a *App) TestURLHook(name string) URLHookResult {
+	for _, h := range loadSettings().URLHooks {
+		if h.Name == name {
+			return deliverURLHook(h, sampleURLEvent())
+		}
+	}
+	return URLHookResult{Name: name, Err: "未找到 webhook " + name}
+}
+
+// onQuickURL: scanQuickURL 获得新地址后调用，依次触发全局与当前预设的 webhook
+func (a *App) onQuickURL(prev, url string) {
+	ev := URLEvent{Event: URLEventAssigned, URL: url, PreviousURL: prev, Time: time.Now()}
+	if prev != "" && prev != url {
+		ev.Event = URLEventChanged
+	}
+	a.emit("quick:url", ev)
+	a.updateURLProviders(url)
+
+	hooks := loadSettings().URLHooks
+	a.quickMu.Lock()
+	hooks = append(hooks, a.quickHooks...)
+	a.quickMu.Unlock()
+	for _, h := range hooks {
+		if h.Disabled {
+			continue
+		}
+		go func(h URLHook) {
+			res := deliverURLHook(h, ev)
+			if res.Err != "" {
+				a.emit("quick:url-hook", res)
+			}
+		}(h)
+	}
+}
+
+// deliverURLHook: 网络错误或 5xx 时最多重试 2 次
+func deliverURLHook(h URLHook, ev URLEvent) URLHookResult {
+	res := URLHookResult{Name: h.Name, URL: ev.URL}
+	body, err := h.render(ev)
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		status, err := sendURLHook(client, h, ev.Event, body)
+		res.Status = status
+		switch {
+		case err != nil:
+			res.Err = err.Error()
+			continue
+		case status >= 500:
+			res.Err = fmt.Sprintf("返回 %d", status)
+			continue
+		case status >= 300:
+			res.Err = fmt.Sprintf("返回 %d", status)
+		default:
+			res.Err = ""
+		}
+		return res
+	}
+	return res
+}
+
+func sendURLHook(client *http.Client, h URLHook, event string, body []byte) (int, error) {
+	req, err := http.NewRequest(h.method(), h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cftunnel-app/"+AppVersion)
+	req.Header.Set(urlHookEventHeader, event)
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	if h.Secret != "" {
+		req.Header.Set(urlHookSignatureHeader, signURLHook(h.Secret, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
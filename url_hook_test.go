@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLHookRenderAndSign(t *testing.T) {
+	type delivery struct {
+		body, sig, event string
+	}
+	got := make(chan delivery, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- delivery{string(body), r.Header.Get(urlHookSignatureHeader), r.Header.Get(urlHookEventHeader)}
+	}))
+	defer srv.Close()
+
+	h := URLHook{
+		Name:   "bot",
+		URL:    srv.URL,
+		Body:   `{"text":{{json (printf "新地址 %s" .URL)}}}`,
+		Secret: "s3cret",
+	}
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	ev := URLEvent{Event: URLEventChanged, URL: `https://a"b.trycloudflare.com`, Time: time.Now()}
+	if res := deliverURLHook(h, ev); res.Err != "" || res.Status != 200 {
+		t.Fatalf("deliverURLHook() = %+v", res)
+	}
+
+	d := <-got
+	var body struct{ Text string }
+	if err := json.Unmarshal([]byte(d.body), &body); err != nil {
+		t.Fatalf("body %q: %v", d.body, err)
+	}
+	if body.Text != "新地址 "+ev.URL {
+		t.Errorf("text = %q", body.Text)
+	}
+	if d.sig != signURLHook("s3cret", []byte(d.body)) || !strings.HasPrefix(d.sig, "sha256=") {
+		t.Errorf("signature = %q", d.sig)
+	}
+	if d.event != URLEventChanged {
+		t.Errorf("event header = %q", d.event)
+	}
+}
+
+func TestURLHookValidate(t *testing.T) {
+	bad := []URLHook{
+		{URL: "https://x"},
+		{Name: "a", URL: "x"},
+		{Name: "a", URL: "https://x", Method: "GET"},
+		{Name: "a", URL: "https://x", Body: `{"url": {{.URL}}}`}, // 未转义，渲染结果不是 JSON
+		{Name: "a", URL: "https://x", Body: `{{.Nope}}`},
+	}
+	for _, h := range bad {
+		if h.Validate() == nil {
+			t.Errorf("Validate(%+v) = nil, want error", h)
+		}
+	}
+	plain := URLHook{Name: "a", URL: "https://x", Body: "url={{.URL}}", Headers: map[string]string{"content-type": "text/plain"}}
+	if err := plain.Validate(); err != nil {
+		t.Errorf("plain text body: %v", err)
+	}
+}
+
+func TestScanQuickURLFiresHooks(t *testing.T) {
+	got := make(chan URLEvent, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev URLEvent
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		got <- ev
+	}))
+	defer srv.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	if msg := a.SaveURLHooks([]URLHook{{Name: "h", URL: srv.URL}}); msg != "已保存" {
+		t.Fatal(msg)
+	}
+
+	log := "INF |  https://first-one.trycloudflare.com  |\nINF |  https://first-one.trycloudflare.com  |\n"
+	a.scanQuickURL(strings.NewReader(log))
+	a.quickURL = "" // 模拟 cloudflared 重启
+	a.scanQuickURL(strings.NewReader("INF |  https://second-one.trycloudflare.com  |\n"))
+
+	want := []URLEvent{
+		{Event: URLEventAssigned, URL: "https://first-one.trycloudflare.com"},
+		{Event: URLEventChanged, URL: "https://second-one.trycloudflare.com", PreviousURL: "https://first-one.trycloudflare.com"},
+	}
+	seen := map[string]URLEvent{}
+	for range want {
+		select {
+		case ev := <-got:
+			seen[ev.URL] = ev
+		case <-time.After(3 * time.Second):
+			t.Fatal("webhook not called")
+		}
+	}
+	for _, w := range want {
+		ev := seen[w.URL]
+		if ev.Event != w.Event || ev.PreviousURL != w.PreviousURL {
+			t.Errorf("event for %s = %+v, want %+v", w.URL, ev, w)
+		}
+	}
+	select {
+	case ev := <-got:
+		t.Errorf("unexpected extra delivery %+v", ev)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ==================== 第三方回调地址自动更新 ====================
+// 临时隧道地址变化后，按配置把新的回调地址（隧道地址 + Path）重新登记到第三方服务：
+//   generic   按模板向任意 API 发送请求（默认 PUT {"url": ...}）
+//   github    更新仓库 webhook 的 config.url
+//   telegram  调用 Bot API setWebhook
+// 各服务的 API 根地址都可通过 APIBase 覆盖，便于指向本地替身服务测试。
+
+type URLProvider struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // generic / github / telegram
+	Disabled bool   `json:"disabled"`
+	Path     string `json:"path,omitempty"`     // 追加到隧道地址后的回调路径，如 /webhook
+	APIBase  string `json:"api_base,omitempty"` // 覆盖默认 API 根地址
+
+	// generic
+	URL     string            `json:"url,omitempty"`
+	Method  string            `json:"method,omitempty"` // 默认 PUT
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"` // text/template，.URL 为回调地址
+
+	// github
+	Owner  string `json:"owner,omitempty"`
+	Repo   string `json:"repo,omitempty"`
+	HookID int64  `json:"hook_id,omitempty"`
+	Token  string `json:"token,omitempty"`  // github 个人访问令牌 / telegram bot token
+	Secret string `json:"secret,omitempty"` // github webhook secret / telegram secret_token
+}
+
+type URLProviderResult struct {
+	Name     string `json:"name"`
+	Callback string `json:"callback"`
+	Err      string `json:"err,omitempty"`
+}
+
+// urlProviderType: 一种服务的校验与登记实现
+type urlProviderType struct {
+	validate func(p URLProvider) error
+	register func(client *http.Client, p URLProvider, callback string) error
+}
+
+var urlProviderTypes = map[string]urlProviderType{
+	"generic":  {validateGenericProvider, registerGeneric},
+	"github":   {validateGitHubProvider, registerGitHub},
+	"telegram": {validateTelegramProvider, registerTelegram},
+}
+
+// httpStatusError: 服务端拒绝；4xx 不重试
+type httpStatusError struct {
+	code int
+	body string
+}
+
+func (e *httpStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("返回 %d", e.code)
+	}
+	return fmt.Sprintf("返回 %d: %s", e.code, e.body)
+}
+
+func (p URLProvider) Validate() error {
+	if p.Name == "" {
+		return errors.New("服务需要名称")
+	}
+	t, ok := urlProviderTypes[p.Type]
+	if !ok {
+		return fmt.Errorf("%s: 不支持的服务类型 %q", p.Name, p.Type)
+	}
+	if p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+		return fmt.Errorf("%s: 回调路径需以 / 开头", p.Name)
+	}
+	if p.APIBase != "" && !strings.HasPrefix(p.APIBase, "http://") && !strings.HasPrefix(p.APIBase, "https://") {
+		return fmt.Errorf("%s: API 地址无效", p.Name)
+	}
+	if err := t.validate(p); err != nil {
+		return fmt.Errorf("%s: %v", p.Name, err)
+	}
+	return nil
+}
+
+func (p URLProvider) apiBase(def string) string {
+	if p.APIBase != "" {
+		return strings.TrimRight(p.APIBase, "/")
+	}
+	return def
+}
+
+func (a *App) GetURLProviders() []URLProvider {
+	return nonNil(loadSettings().URLProviders)
+}
+
+func (a *App) SaveURLProviders(providers []URLProvider) string {
+	seen := map[string]bool{}
+	for _, p := range providers {
+		if err := p.Validate(); err != nil {
+			return "错误: " + err.Error()
+		}
+		if seen[p.Name] {
+			return "错误: 服务重名: " + p.Name
+		}
+		seen[p.Name] = true
+	}
+	if _, err := updateSettings(func(s *AppSettings) { s.URLProviders = providers }); err != nil {
+		return "错误: " + err.Error()
+	}
+	return "已保存"
+}
+
+// SyncURLProviders: 用当前临时隧道地址立即重新登记全部服务
+func (a *App) SyncURLProviders() []URLProviderResult {
+	url := a.QuickURL()
+	if url == "" {
+		return []URLProviderResult{{Err: "临时隧道未运行"}}
+	}
+	return registerURLProviders(loadSettings().URLProviders, url)
+}
+
+// updateURLProviders: onQuickURL 中异步调用，有失败时推送 quick:url-provider 事件
+func (a *App) updateURLProviders(url string) {
+	providers := loadSettings().URLProviders
+	if len(providers) == 0 {
+		return
+	}
+	go func() {
+		results := registerURLProviders(providers, url)
+		for _, r := range results {
+			if r.Err != "" {
+				a.emit("quick:url-provider", results)
+				return
+			}
+		}
+	}()
+}
+
+func registerURLProviders(providers []URLProvider, url string) []URLProviderResult {
+	client := &http.Client{Timeout: 15 * time.Second}
+	results := []URLProviderResult{}
+	for _, p := range providers {
+		if p.Disabled {
+			continue
+		}
+		res := URLProviderResult{Name: p.Name, Callback: strings.TrimRight(url, "/") + p.Path}
+		if err := registerURLProvider(client, p, res.Callback); err != nil {
+			res.Err = err.Error()
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// registerURLProvider: 网络错误或 5xx 时最多重试 2 次
+func registerURLProvider(client *http.Client, p URLProvider, callback string) error {
+	t, ok := urlProviderTypes[p.Type]
+	if !ok {
+		return fmt.Errorf("不支持的服务类型 %q", p.Type)
+	}
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		err = t.register(client, p, callback)
+		var se *httpStatusError
+		if err == nil || (errors.As(err, &se) && se.code < 500) {
+			return err
+		}
+	}
+	return err
+}
+
+// doProviderRequest: 发送 JSON 请求，非 2xx 时返回 httpStatusError，成功时返回响应体
+func doProviderRequest(client *http.Client, method, url string, headers map[string]string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cftunnel-app/"+AppVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return nil, &httpStatusError{code: resp.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+	return data, nil
+}
+
+// ---------- generic ----------
+
+const defaultGenericProviderBody = `{"url":{{json .URL}}}`
+
+// genericHook: generic 服务复用地址变更 webhook 的模板渲染
+func (p URLProvider) genericHook() URLHook {
+	h := URLHook{Name: p.Name, URL: p.URL, Method: p.Method, Headers: p.Headers, Body: p.Body}
+	if h.Method == "" {
+		h.Method = http.MethodPut
+	}
+	if h.Body == "" {
+		h.Body = defaultGenericProviderBody
+	}
+	return h
+}
+
+func validateGenericProvider(p URLProvider) error {
+	if err := p.genericHook().Validate(); err != nil {
+		return errors.New(strings.TrimPrefix(err.Error(), p.Name+": "))
+	}
+	return nil
+}
+
+func registerGeneric(client *http.Client, p URLProvider, callback string) error {
+	h := p.genericHook()
+	body, err := h.render(URLEvent{Event: URLEventChanged, URL: callback, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = doProviderRequest(client, h.method(), h.URL, h.Headers, body)
+	return err
+}
+
+// ---------- github ----------
+
+const defaultGitHubAPI = "https://api.github.com"
+
+func validateGitHubProvider(p URLProvider) error {
+	if p.Owner == "" || p.Repo == "" || p.HookID <= 0 {
+		return errors.New("需要仓库所有者、仓库名与 webhook ID")
+	}
+	if p.Token == "" {
+		return errors.New("需要访问令牌")
+	}
+	return nil
+}
+
+// registerGitHub: PATCH /repos/{owner}/{repo}/hooks/{id}；config 会整体替换，需带上 content_type 与 secret
+func registerGitHub(client *http.Client, p URLProvider, callback string) error {
+	config := map[string]string{"url": callback, "content_type": "json", "insecure_ssl": "0"}
+	if p.Secret != "" {
+		config["secret"] = p.Secret
+	}
+	body, _ := json.Marshal(map[string]any{"config": config})
+	url := fmt.Sprintf("%s/repos/%s/%s/hooks/%d", p.apiBase(defaultGitHubAPI), p.Owner, p.Repo, p.HookID)
+	_, err := doProviderRequest(client, http.MethodPatch, url, map[string]string{
+		"Accept":               "application/vnd.github+json",
+		"Authorization":        "Bearer " + p.Token,
+		"X-GitHub-Api-Version": "2022-11-28",
+	}, body)
+	return err
+}
+
+// ---------- telegram ----------
+
+const defaultTelegramAPI = "https://api.telegram.org"
+
+func validateTelegramProvider(p URLProvider) error {
+	if p.Token == "" {
+		return errors.New("需要 bot token")
+	}
+	return nil
+}
+
+func registerTelegram(client *http.Client, p URLProvider, callback string) error {
+	req := map[string]string{"url": callback}
+	if p.Secret != "" {
+		req["secret_token"] = p.Secret
+	}
+	body, _ := json.Marshal(req)
+	data, err := doProviderRequest(client, http.MethodPost, p.apiBase(defaultTelegramAPI)+"/bot"+p.Token+"/setWebhook", nil, body)
+	var resp struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err != nil {
+		var se *httpStatusError
+		if errors.As(err, &se) && json.Unmarshal([]byte(se.body), &resp) == nil && resp.Description != "" {
+			return &httpStatusError{code: se.code, body: resp.Description}
+		}
+		// bot token 在请求地址中，url.Error 会带出完整地址
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			return fmt.Errorf("setWebhook 请求失败: %v", ue.Err)
+		}
+		return err
+	}
+	if json.Unmarshal(data, &resp) != nil || !resp.OK {
+		return fmt.Errorf("setWebhook 失败: %s", resp.Description)
+	}
+	return nil
+}
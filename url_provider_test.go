@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestURLProviderRegistration(t *testing.T) {
+	var calls atomic.Int64
+	got := map[string]map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got[r.Method+" "+r.URL.Path] = body
+		switch r.URL.Path {
+		case "/repos/me/app/hooks/42":
+			if r.Header.Get("Authorization") != "Bearer ghp" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		case "/botBAD/setWebhook":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"ok":false,"description":"Bad Request: bad webhook"}`))
+		case "/botT0K/setWebhook":
+			_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+		}
+	}))
+	defer srv.Close()
+
+	providers := []URLProvider{
+		{Name: "gh", Type: "github", APIBase: srv.URL, Owner: "me", Repo: "app", HookID: 42, Token: "ghp", Secret: "s", Path: "/github"},
+		{Name: "tg", Type: "telegram", APIBase: srv.URL, Token: "T0K", Path: "/tg"},
+		{Name: "tg-bad", Type: "telegram", APIBase: srv.URL, Token: "BAD"},
+		{Name: "cfg", Type: "generic", URL: srv.URL + "/config", Body: `{"endpoint":{{json .URL}}}`},
+		{Name: "off", Type: "generic", URL: srv.URL + "/off", Disabled: true},
+	}
+	for _, p := range providers {
+		if err := p.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := registerURLProviders(providers, "https://x.trycloudflare.com/")
+	errs := map[string]string{}
+	for _, r := range results {
+		errs[r.Name] = r.Err
+	}
+	if len(results) != 4 || errs["gh"] != "" || errs["tg"] != "" || errs["cfg"] != "" {
+		t.Fatalf("results = %+v", results)
+	}
+	if !strings.Contains(errs["tg-bad"], "bad webhook") {
+		t.Errorf("tg-bad err = %q", errs["tg-bad"])
+	}
+	if calls.Load() != 4 {
+		t.Errorf("calls = %d, want 4 (4xx must not be retried)", calls.Load())
+	}
+
+	config, _ := got["PATCH /repos/me/app/hooks/42"]["config"].(map[string]any)
+	if config["url"] != "https://x.trycloudflare.com/github" || config["secret"] != "s" || config["content_type"] != "json" {
+		t.Errorf("github config = %v", config)
+	}
+	if u := got["POST /botT0K/setWebhook"]["url"]; u != "https://x.trycloudflare.com/tg" {
+		t.Errorf("telegram url = %v", u)
+	}
+	if u := got["PUT /config"]["endpoint"]; u != "https://x.trycloudflare.com" {
+		t.Errorf("generic endpoint = %v", u)
+	}
+}
+
+func TestTelegramErrorHidesToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	p := URLProvider{Name: "tg", Type: "telegram", APIBase: srv.URL, Token: "123:SECRET"}
+	err := registerTelegram(&http.Client{}, p, "https://x.trycloudflare.com")
+	if err == nil || strings.Contains(err.Error(), "SECRET") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestURLProviderValidate(t *testing.T) {
+	bad := []URLProvider{
+		{Type: "github"},
+		{Name: "a", Type: "gitlab"},
+		{Name: "a", Type: "github", Owner: "o", Repo: "r", Token: "t"},
+		{Name: "a", Type: "telegram"},
+		{Name: "a", Type: "telegram", Token: "t", Path: "hook"},
+		{Name: "a", Type: "generic", URL: "ftp://x"},
+		{Name: "a", Type: "telegram", Token: "t", APIBase: "localhost:1"},
+	}
+	for _, p := range bad {
+		if p.Validate() == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// ==================== 凭据库 ====================
+// 中继令牌与 SSH 密码加密保存在状态目录的 vault.json 中，供再次初始化、切换中继配置时复用。
+// Windows 使用 DPAPI 绑定到当前用户，无需口令；其他平台用用户口令经 Argon2id 派生密钥，
+// AES-256-GCM 加密，解锁后密钥只保存在内存中。条目名（不含密文）以明文保存，未解锁时也能列出。
+// 条目名约定: relay-token:<服务器>、ssh-password:<用户>@<主机>:<端口>。
+
+const (
+	vaultVersion   = 1
+	vaultKindDPAPI = "dpapi"
+	vaultKindPass  = "passphrase"
+
+	minVaultPassphrase = 8
+)
+
+// vaultKDF: Argon2id 参数随文件保存，以后调整默认值不影响已有凭据库
+type vaultKDF struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"` // KiB
+	Threads uint8  `json:"threads"`
+}
+
+var defaultVaultKDF = vaultKDF{Time: 1, Memory: 64 * 1024, Threads: 4}
+
+type vaultFile struct {
+	Version int       `json:"version"`
+	Kind    string    `json:"kind"`
+	KDF     *vaultKDF `json:"kdf,omitempty"`
+	Salt    []byte    `json:"salt,omitempty"`
+	Keys    []string  `json:"keys"`
+	Data    []byte    `json:"data"`
+}
+
+type VaultStatusInfo struct {
+	Kind     string   `json:"kind"` // dpapi / passphrase
+	Exists   bool     `json:"exists"`
+	Unlocked bool     `json:"unlocked"`
+	Keys     []string `json:"keys"`
+	Err      string   `json:"err,omitempty"`
+}
+
+// vaultProtector: 加解密整个条目表
+type vaultProtector interface {
+	seal(plain []byte) ([]byte, error)
+	open(sealed []byte) ([]byte, error)
+}
+
+var errVaultLocked = errors.New("凭据库未解锁")
+
+type credentialVault struct {
+	mu      sync.Mutex
+	prot    vaultProtector // 未解锁时为 nil
+	file    vaultFile
+	entries map[string]string
+}
+
+func vaultPath() string {
+	return filepath.Join(stateDir(), "vault.json")
+}
+
+func relayTokenKey(server string) string {
+	return "relay-token:" + server
+}
+
+func sshPasswordKey(user, host string, port int) string {
+	return fmt.Sprintf("ssh-password:%s@%s:%d", user, host, port)
+}
+
+// ---------- App 接口 ----------
+
+func (a *App) GetVaultStatus() VaultStatusInfo {
+	return a.vault.status()
+}
+
+// UnlockVault: 口令方式的凭据库首次解锁时以该口令新建
+func (a *App) UnlockVault(passphrase string) string {
+	if err := a.vault.unlock(passphrase); err != nil {
+		return "错误: " + err.Error()
+	}
+	return "已解锁"
+}
+
+func (a *App) LockVault() string {
+	a.vault.lock()
+	return "已锁定"
+}
+
+func (a *App) SaveCredential(key, secret string) string {
+	key = strings.TrimSpace(key)
+	if key == "" || secret == "" {
+		return "错误: 名称与内容不能为空"
+	}
+	if err := a.vault.set(key, secret); err != nil {
+		return "错误: " + err.Error()
+	}
+	return "已保存"
+}
+
+func (a *App) DeleteCredential(key string) string {
+	if err := a.vault.delete(key); err != nil {
+		return "错误: " + err.Error()
+	}
+	return "已删除"
+}
+
+// ---------- 实现 ----------
+
+func newCredentialVault() *credentialVault {
+	return &credentialVault{}
+}
+
+// load: 读取文件头；不存在时按平台选择新凭据库的类型
+func (v *credentialVault) load() (bool, error) {
+	data, err := os.ReadFile(vaultPath())
+	if os.IsNotExist(err) {
+		v.file = vaultFile{Version: vaultVersion, Kind: vaultKindPass}
+		if osVaultProtector() != nil {
+			v.file.Kind = vaultKindDPAPI
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return true, fmt.Errorf("凭据库文件损坏: %v", err)
+	}
+	if f.Version > vaultVersion {
+		return true, fmt.Errorf("不支持的凭据库版本 %d", f.Version)
+	}
+	v.file = f
+	return true, nil
+}
+
+func (v *credentialVault) status() VaultStatusInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	exists, err := v.load()
+	info := VaultStatusInfo{Kind: v.file.Kind, Exists: exists, Keys: nonNil(v.file.Keys)}
+	if err != nil {
+		info.Err = err.Error()
+		return info
+	}
+	if v.prot == nil && v.file.Kind == vaultKindDPAPI {
+		if err := v.openLocked(osVaultProtector(), exists); err != nil {
+			info.Err = err.Error()
+		}
+	}
+	info.Unlocked = v.prot != nil
+	return info
+}
+
+func (v *credentialVault) unlock(passphrase string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	exists, err := v.load()
+	if err != nil {
+		return err
+	}
+	if v.file.Kind == vaultKindDPAPI {
+		return v.openLocked(osVaultProtector(), exists)
+	}
+	if v.file.Kind != vaultKindPass {
+		return fmt.Errorf("当前平台不支持 %s 凭据库", v.file.Kind)
+	}
+	if !exists {
+		if len(passphrase) < minVaultPassphrase {
+			return fmt.Errorf("口令至少 %d 个字符", minVaultPassphrase)
+		}
+		kdf := defaultVaultKDF
+		v.file.KDF = &kdf
+		v.file.Salt = make([]byte, 16)
+		if _, err := rand.Read(v.file.Salt); err != nil {
+			return err
+		}
+	}
+	if v.file.KDF == nil {
+		return errors.New("凭据库缺少密钥派生参数")
+	}
+	k := v.file.KDF
+	prot, err := newPassphraseProtector(argon2.IDKey([]byte(passphrase), v.file.Salt, k.Time, k.Memory, k.Threads, 32))
+	if err != nil {
+		return err
+	}
+	return v.openLocked(prot, exists)
+}
+
+// openLocked: 解密条目表；新建的凭据库立即写入，以便之后用同一口令解锁
+func (v *credentialVault) openLocked(prot vaultProtector, exists bool) error {
+	if prot == nil {
+		return errors.New("当前平台不支持系统凭据保护")
+	}
+	entries := map[string]string{}
+	if exists {
+		plain, err := prot.open(v.file.Data)
+		if err != nil {
+			if v.file.Kind == vaultKindPass {
+				return errors.New("口令错误")
+			}
+			return fmt.Errorf("解密凭据库失败: %v", err)
+		}
+		if err := json.Unmarshal(plain, &entries); err != nil {
+			return fmt.Errorf("凭据库内容损坏: %v", err)
+		}
+	}
+	v.prot, v.entries = prot, entries
+	if !exists {
+		return v.saveLocked()
+	}
+	return nil
+}
+
+func (v *credentialVault) lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.prot, v.entries = nil, nil
+}
+
+// ready: DPAPI 凭据库按需自动解锁
+func (v *credentialVault) ready() error {
+	if v.prot != nil {
+		return nil
+	}
+	exists, err := v.load()
+	if err != nil {
+		return err
+	}
+	if v.file.Kind == vaultKindDPAPI {
+		return v.openLocked(osVaultProtector(), exists)
+	}
+	return errVaultLocked
+}
+
+// get: 未保存返回空串
+func (v *credentialVault) get(key string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.ready(); err != nil {
+		return "", err
+	}
+	return v.entries[key], nil
+}
+
+func (v *credentialVault) has(key string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, err := v.load(); err != nil {
+		return false
+	}
+	for _, k := range v.file.Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *credentialVault) set(key, secret string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.ready(); err != nil {
+		return err
+	}
+	v.entries[key] = secret
+	return v.saveLocked()
+}
+
+func (v *credentialVault) delete(key string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.ready(); err != nil {
+		return err
+	}
+	if _, ok := v.entries[key]; !ok {
+		return errors.New("未找到凭据 " + key)
+	}
+	delete(v.entries, key)
+	return v.saveLocked()
+}
+
+func (v *credentialVault) saveLocked() error {
+	plain, err := json.Marshal(v.entries)
+	if err != nil {
+		return err
+	}
+	sealed, err := v.prot.seal(plain)
+	if err != nil {
+		return fmt.Errorf("加密凭据库失败: %v", err)
+	}
+	v.file.Version, v.file.Data = vaultVersion, sealed
+	v.file.Keys = make([]string, 0, len(v.entries))
+	for k := range v.entries {
+		v.file.Keys = append(v.file.Keys, k)
+	}
+	sort.Strings(v.file.Keys)
+	data, err := json.MarshalIndent(v.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	_ = os.MkdirAll(stateDir(), 0700)
+	tmp := vaultPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, vaultPath())
+}
+
+// passphraseProtector: AES-256-GCM，随机 nonce 放在密文前
+type passphraseProtector struct {
+	aead cipher.AEAD
+}
+
+func newPassphraseProtector(key []byte) (*passphraseProtector, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &passphraseProtector{aead: aead}, nil
+}
+
+func (p *passphraseProtector) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return p.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (p *passphraseProtector) open(sealed []byte) ([]byte, error) {
+	n := p.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("密文过短")
+	}
+	return p.aead.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+// ==================== 敏感参数 ====================
+// 内核命令行参数对同一台机器上的所有用户可见（任务管理器、ps），令牌与密码改由标准输入传递：
+// 去掉 --token/--pass 及其值，换成对应的 --token-stdin/--pass-stdin 开关。是否支持以子命令
+// --help 的参数列表为准；不支持时拒绝执行，不会退回命令行传递。只用于 relay init、relay server setup。
+
+var secretStdinFlags = map[string]string{
+	"--token": "--token-stdin",
+	"--pass":  "--pass-stdin",
+}
+
+var errSecretUnsupported = errors.New("当前内核不支持安全传递密码，请升级 cftunnel 内核")
+
+// secretHandoff: 按子命令缓存帮助中列出的参数，探测失败时不缓存
+type secretHandoff struct {
+	help  cftunnelRunner
+	mu    sync.Mutex
+	cache map[string]map[string]bool
+}
+
+var cftunnelSecrets = &secretHandoff{help: runCftunnel}
+
+// runCftunnelSecret: 与 runCftunnel 相同，敏感参数经标准输入传递
+func runCftunnelSecret(args ...string) (string, error) {
+	args, stdin, err := cftunnelSecrets.split(args)
+	if err != nil {
+		return "", err
+	}
+	return runCftunnelInput(stdin, args...)
+}
+
+// split: 把敏感参数换成标准输入开关，返回新参数与要写入标准输入的内容
+func (h *secretHandoff) split(args []string) ([]string, string, error) {
+	var rest, flags, values []string
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := strings.Cut(args[i], "=")
+		if _, ok := secretStdinFlags[flag]; ok && (inline || i+1 < len(args)) {
+			if !inline {
+				i++
+				value = args[i]
+			}
+			flags, values = append(flags, flag), append(values, value)
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	if len(flags) == 0 {
+		return args, "", nil
+	}
+	if len(flags) > 1 {
+		return nil, "", errors.New("一次只能通过标准输入传递一个敏感参数")
+	}
+	known, err := h.flags(args)
+	if err != nil {
+		return nil, "", fmt.Errorf("检测内核是否支持安全传递密码失败: %v", err)
+	}
+	stdinFlag := secretStdinFlags[flags[0]]
+	if !known[stdinFlag] {
+		return nil, "", errSecretUnsupported
+	}
+	return append(rest, stdinFlag), values[0] + "\n", nil
+}
+
+func (h *secretHandoff) flags(args []string) (map[string]bool, error) {
+	var sub []string
+	for _, a := range args {
+		if strings.HasPrefix(a, "-") {
+			break
+		}
+		sub = append(sub, a)
+	}
+	key := strings.Join(sub, " ")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if known, ok := h.cache[key]; ok {
+		return known, nil
+	}
+	out, err := h.help(append(sub, "--help")...)
+	if err != nil {
+		return nil, err
+	}
+	known := parseHelpFlags(out)
+	if h.cache == nil {
+		h.cache = map[string]map[string]bool{}
+	}
+	h.cache[key] = known
+	return known, nil
+}
+
+// parseHelpFlags: 帮助中每行开头列出的参数名，如 "  -t, --token string" 中的 -t 与 --token
+func parseHelpFlags(help string) map[string]bool {
+	known := map[string]bool{}
+	for _, line := range strings.Split(help, "\n") {
+		for _, field := range strings.Fields(line) {
+			if !strings.HasPrefix(field, "-") {
+				break
+			}
+			known[strings.TrimRight(field, ",")] = true
+		}
+	}
+	return known
+}
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package main
+
+// osVaultProtector: 非 Windows 平台没有免依赖的系统凭据保护，使用口令加密
+func osVaultProtector() vaultProtector { return nil }
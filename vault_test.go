@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCredentialVault(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	if st := a.GetVaultStatus(); st.Exists || st.Unlocked || st.Kind != vaultKindPass {
+		t.Fatalf("status = %+v", st)
+	}
+	if msg := a.SaveCredential("relay-token:vps", "s3cret"); !strings.Contains(msg, "未解锁") {
+		t.Errorf("save while locked: %s", msg)
+	}
+	if msg := a.UnlockVault("short"); !strings.HasPrefix(msg, "错误") {
+		t.Error("short passphrase accepted")
+	}
+	if msg := a.UnlockVault("correct horse"); msg != "已解锁" {
+		t.Fatal(msg)
+	}
+	if msg := a.SaveCredential("relay-token:vps", "s3cret"); msg != "已保存" {
+		t.Fatal(msg)
+	}
+
+	data, err := os.ReadFile(vaultPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Error("secret stored in plain text")
+	}
+
+	// 新实例用错误口令无法解锁，条目名仍可列出
+	b := NewApp()
+	if st := b.GetVaultStatus(); !st.Exists || st.Unlocked || !reflect.DeepEqual(st.Keys, []string{"relay-token:vps"}) {
+		t.Errorf("status = %+v", st)
+	}
+	if msg := b.UnlockVault("wrong horse"); !strings.Contains(msg, "口令错误") {
+		t.Errorf("wrong passphrase: %s", msg)
+	}
+	b.UnlockVault("correct horse")
+	if got, err := b.vault.get("relay-token:vps"); err != nil || got != "s3cret" {
+		t.Errorf("get = %q, %v", got, err)
+	}
+	if msg := b.DeleteCredential("relay-token:vps"); msg != "已删除" {
+		t.Error(msg)
+	}
+	b.LockVault()
+	if _, err := b.vault.get("relay-token:vps"); err != errVaultLocked {
+		t.Errorf("get after lock: %v", err)
+	}
+}
+
+func TestSecretHandoff(t *testing.T) {
+	helps := map[string]string{
+		// 支持从标准输入读取令牌；说明文字中的参数名不算
+		"relay init --help": `Flags:
+      --server string   中继服务器地址
+      --token string    中继令牌，建议改用 --token-stdin
+      --token-stdin     从标准输入读取中继令牌`,
+		// 旧内核：只能在命令行传递密码
+		"relay server setup --help": `Flags:
+      --host string   服务器地址
+      --pass string   SSH 密码，也可用 --pass-stdin`,
+	}
+	var probes []string
+	fail := true
+	h := &secretHandoff{help: func(args ...string) (string, error) {
+		cmd := strings.Join(args, " ")
+		probes = append(probes, cmd)
+		if fail {
+			return "", errors.New("executable file not found")
+		}
+		return helps[cmd], nil
+	}}
+
+	// 探测失败：拒绝执行且不缓存
+	if _, _, err := h.split(relayInitArgs("1.2.3.4:7000", "abc")); err == nil {
+		t.Error("secret passed without probing the kernel")
+	}
+	fail = false
+	for i := 0; i < 2; i++ {
+		args, stdin, err := h.split(relayInitArgs("1.2.3.4:7000", "abc"))
+		if err != nil || stdin != "abc\n" ||
+			!reflect.DeepEqual(args, []string{"relay", "init", "--server", "1.2.3.4:7000", "--token-stdin"}) {
+			t.Errorf("init: args = %q stdin = %q err = %v", args, stdin, err)
+		}
+	}
+	setup := []string{"relay", "server", "setup", "--host", "h", "--pass=p w"}
+	if args, _, err := h.split(setup); err != errSecretUnsupported || args != nil {
+		t.Errorf("setup: args = %q err = %v", args, err)
+	}
+	// 不含敏感参数的命令不探测
+	if args, stdin, err := h.split([]string{"relay", "server", "setup", "--key", "id_rsa"}); err != nil || len(args) != 5 || stdin != "" {
+		t.Errorf("key setup: args = %q err = %v", args, err)
+	}
+	if !reflect.DeepEqual(probes, []string{"relay init --help", "relay init --help", "relay server setup --help"}) {
+		t.Errorf("probes = %q", probes)
+	}
+}
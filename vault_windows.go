@@ -0,0 +1,43 @@
+//go:build windows
+
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// dpapiProtector: CryptProtectData 以当前 Windows 用户的登录凭据加密，换用户或换机器无法解密
+type dpapiProtector struct{}
+
+func osVaultProtector() vaultProtector { return dpapiProtector{} }
+
+func (dpapiProtector) seal(plain []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(newDataBlob(plain), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeDataBlob(&out), nil
+}
+
+func (dpapiProtector) open(sealed []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(newDataBlob(sealed), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeDataBlob(&out), nil
+}
+
+func newDataBlob(b []byte) *windows.DataBlob {
+	if len(b) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+}
+
+// takeDataBlob: 复制系统分配的输出并释放
+func takeDataBlob(b *windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(b.Data)))
+	return append([]byte(nil), unsafe.Slice(b.Data, b.Size)...)
+}